@@ -16,7 +16,7 @@ function paintState(canvas, stateNr, x, y, nr)
 	text.setAttribute('y', y)
 	text.setAttribute('class', 'stateLabel')
 
-	text.innerHTML = stateNr
+	text.textContent = stateNr
 
 	// All state graphical elements are gathered in a group that manages
 	// metadata and events
@@ -24,27 +24,41 @@ function paintState(canvas, stateNr, x, y, nr)
 
 	let showFn = showPopup(state, nr)
 
-	group.addEventListener('mouseover', showFn)
-	group.addEventListener('click', showFn)
+	group.addEventListener('mouseover', function (event) {
+		if (!document.getElementById('state-popup').pinned)
+			showFn(event)
+	})
+
+	// Clicking pins the popup, so that its subterms can be expanded
+	group.addEventListener('click', function (event) {
+		showFn(event)
+		document.getElementById('state-popup').pinned = true
+		event.stopPropagation()
+	})
 
 	group.addEventListener('mouseout', function () {
-		document.getElementById('state-popup').style.visibility = 'hidden'
-		document.getElementById('popup-term').innerText = ''
-		document.getElementById('popup-strat').innerText = ''
+		if (!document.getElementById('state-popup').pinned)
+			hidePopup()
 	})
 
 	group.appendChild(circle)
 	group.appendChild(text)
 	canvas.appendChild(group)
 
+	// The circles are kept to highlight the current state of the player
+	canvas.circles[stateNr] = circle
+
 	return circle
 }
 
 function showPopup(state, nr)
 {
 	return function (event) {
-		document.getElementById('popup-term').innerHTML = state.term
-		document.getElementById('popup-strat').innerHTML = state.strategy
+		// The raw term is shown instead of the simplified one if requested
+		var rawTerms = document.getElementById('raw-terms')
+		var showRaw = rawTerms && rawTerms.checked && state.rawTerm
+		renderView(document.getElementById('popup-term'), showRaw ? state.rawView : state.view)
+		renderView(document.getElementById('popup-strat'), state.stratView)
 		var popup = document.getElementById('state-popup')
 		// Calculates the preferred size of the popup
 		popup.style.top = '0px'
@@ -61,6 +75,112 @@ function showPopup(state, nr)
 	}
 }
 
+function hidePopup()
+{
+	var popup = document.getElementById('state-popup')
+	popup.pinned = false
+	popup.style.visibility = 'hidden'
+	document.getElementById('popup-term').textContent = ''
+	document.getElementById('popup-strat').textContent = ''
+}
+
+// Renders the structured view of a term or strategy given by the server
+function renderView(element, view)
+{
+	element.textContent = ''
+
+	if (view)
+		element.appendChild(viewElement(view))
+}
+
+// Builds the element for a view, whose subterms can be collapsed and whose
+// elided arguments can be expanded
+function viewElement(view)
+{
+	var node = document.createElement('span')
+	node.className = view.changed ? 'term-node term-changed' : 'term-node'
+
+	appendTokens(node, view.parts[0])
+
+	if (!view.args || view.args.length == 0)
+		return node
+
+	var toggle = document.createElement('span')
+	toggle.className = 'term-toggle'
+	toggle.title = 'Collapse or expand this subterm'
+	toggle.addEventListener('click', function (event) {
+		node.classList.toggle('collapsed')
+		event.stopPropagation()
+	})
+	node.insertBefore(toggle, node.firstChild)
+
+	var body = document.createElement('span')
+	body.className = 'term-body'
+
+	for (var i = 0; i < view.args.length; i++)
+	{
+		// Consecutive elided arguments are replaced by a button
+		if (view.args[i].elided)
+		{
+			var hidden = document.createElement('span')
+			var count = 0
+			hidden.className = 'term-hidden'
+
+			for (; i < view.args.length && view.args[i].elided; i++, count++)
+			{
+				hidden.appendChild(viewElement(view.args[i]))
+				appendTokens(hidden, view.parts[i+1])
+			}
+
+			var more = document.createElement('span')
+			more.className = 'term-more'
+			more.textContent = `… ${count} more …`
+			more.addEventListener('click', function (event) {
+				this.nextSibling.classList.remove('term-hidden')
+				this.remove()
+				event.stopPropagation()
+			})
+
+			body.appendChild(more)
+			body.appendChild(hidden)
+			i--
+			continue
+		}
+
+		body.appendChild(viewElement(view.args[i]))
+
+		if (i + 1 < view.args.length)
+			appendTokens(body, view.parts[i+1])
+	}
+
+	var ellipsis = document.createElement('span')
+	ellipsis.className = 'term-ellipsis'
+	ellipsis.textContent = '…'
+
+	node.appendChild(body)
+	node.appendChild(ellipsis)
+	appendTokens(node, view.parts[view.args.length])
+
+	return node
+}
+
+// Appends some tokens given by the server as text, which is never
+// interpreted as markup
+function appendTokens(element, tokens)
+{
+	for (const token of tokens)
+	{
+		var span = document.createElement('span')
+		span.className = 'tok-' + token.class
+		span.textContent = token.text
+		element.appendChild(span)
+
+		// Long terms may be broken after commas
+		if (token.text == ',')
+			element.appendChild(document.createElement('wbr'))
+	}
+}
+
 function transitionText(transition)
 {
 	switch (transition.type)
@@ -90,7 +210,8 @@ function paintSelfLoop(canvas, state, transition, nr)
 	label.setAttribute('x', x)
 	label.setAttribute('y', y - 2 * nr)
 	label.setAttribute('class', 'transitionLabel')
-	label.innerHTML = transitionText(transition)
+	label.textContent = transitionText(transition)
+	addSourceListener(label, transition)
 	canvas.appendChild(label)
 }
 
@@ -127,10 +248,72 @@ function paintTransition(canvas, source, target, transition, nr)
 	label.setAttribute('y', (y0 + y) / 2 - 5)
 	label.setAttribute('class', 'transitionLabel')
 	label.setAttribute('transform', `rotate(${angle * 180 / Math.PI} ${(x0 + x) / 2} ${(y0 + y) / 2 - 5})`)
-	label.innerHTML = transitionText(transition)
+	label.textContent = transitionText(transition)
+	addSourceListener(label, transition)
 	canvas.appendChild(label)
 }
 
+// Clicking on the label of a rule or opaque transition shows the statements
+// behind it
+function addSourceListener(label, transition)
+{
+	if (transition.type == 0)
+		return
+
+	label.classList.add('withSource')
+	label.addEventListener('click', function (event) {
+		showTransitionSource(transition, event)
+	})
+}
+
+// Shows the rules or strategy definitions behind a transition, which are
+// obtained from the server
+function showTransitionSource(transition, event)
+{
+	var popup = document.getElementById('source-popup')
+	var text = document.getElementById('source-text')
+
+	document.getElementById('source-title').textContent = transitionText(transition)
+	text.textContent = 'Loading...'
+
+	popup.style.top = `${event.pageY + 10}px`
+	popup.style.left = `${Math.max(event.pageX - popup.clientWidth / 2, 0)}px`
+	popup.style.visibility = 'visible'
+	event.stopPropagation()
+
+	const request = new XMLHttpRequest()
+
+	request.onreadystatechange = function()
+	{
+		if (this.readyState != XMLHttpRequest.DONE)
+			return
+
+		if (this.status != 200)
+			text.textContent = this.responseText
+		else
+		{
+			var answer = JSON.parse(this.responseText)
+
+			if (!answer.available)
+				text.textContent = 'The module where the dump was generated is not loaded.'
+			else if (answer.statements.length == 0)
+				text.textContent = transition.type == 2 ? 'No definitions of this strategy were found.'
+					: 'No rules with this label were found.'
+			else
+				text.textContent = answer.statements.join('\n')
+		}
+	}
+
+	var question = new FormData()
+
+	question.append('question', 'transition')
+	question.append('label', transition.label)
+	question.append('type', transition.type)
+
+	request.open('post', 'ask')
+	request.send(question)
+}
+
 function paintCanvas(canvas, graph) {
 	var width = canvas.parentNode.clientWidth
 	var height = canvas.parentNode.clientHeight * 0.95
@@ -146,6 +329,8 @@ function paintCanvas(canvas, graph) {
 	// Node radius
 	var nr = 20
 
+	graph.circles = new Map()
+
 	// The cycle is painted as an ellipse
 	var cycleRadiusY = cycleLength > 1 ? height / 2 - 2 * nr : 0
 	var cycleRadiusX = cycleRadiusY
@@ -216,9 +401,183 @@ function paintCanvas(canvas, graph) {
 
 		var transition = sourceState.successors.find(tr => tr.target == targetNr)
 
-		paintTransition(graph, source, target, transition, nr)
+		// The cycle of invariant witnesses is a single state without loop
+		if (transition)
+			paintTransition(graph, source, target, transition, nr)
+	}
+
+	// Clicking outside a pinned popup hides it
+	if (!graph.listening)
+	{
+		document.addEventListener('click', function (event) {
+			var popup = document.getElementById('state-popup')
+
+			if (popup.pinned && !popup.contains(event.target))
+				hidePopup()
+
+			var sourcePopup = document.getElementById('source-popup')
+
+			if (!sourcePopup.contains(event.target))
+				sourcePopup.style.visibility = 'hidden'
+		})
+
+		graph.listening = true
 	}
 
 	// Adjusts the font size
 	graph.style.fontSize = `${(nr / 20) * parseInt(window.getComputedStyle(document.body).fontSize)}px`
 }
+
+// Step-through player of the counterexample, which walks the path and then
+// loops the cycle (or stops at its state for invariant witnesses)
+var player = {graph: null, position: 0}
+
+// State number at a position of the player
+function playerState(position)
+{
+	const db = player.graph.db
+
+	if (position < db.path.length)
+		return db.path[position]
+
+	if (db.invariant)
+		return db.cycle[0]
+
+	return db.cycle[(position - db.path.length) % db.cycle.length]
+}
+
+function initPlayer(graph)
+{
+	player.graph = graph
+
+	// There is nothing to walk if the property holds
+	if (graph.db.cycle.length == 0)
+	{
+		document.getElementById('player-toggle').style.display = 'none'
+		return
+	}
+
+	document.addEventListener('keydown', function (event) {
+		if (document.getElementById('player').style.display == 'none' ||
+		    event.target.tagName == 'INPUT')
+			return
+
+		switch (event.key)
+		{
+			case 'ArrowRight' : playerStep(1) ; break
+			case 'ArrowLeft'  : playerStep(-1) ; break
+			case 'Home'       : playerStep(-player.position) ; break
+			default: return
+		}
+
+		event.preventDefault()
+	})
+}
+
+// Shows or hides the player, repainting the graph in the remaining space
+function togglePlayer()
+{
+	var panel = document.getElementById('player')
+	var graph = player.graph
+	var canvas = document.getElementById('canvas')
+
+	panel.style.display = panel.style.display == 'none' ? 'block' : 'none'
+
+	// The canvas is shrunk so that its container takes the available space
+	canvas.setAttribute('height', 0)
+	graph.textContent = ''
+	paintCanvas(canvas, graph)
+
+	if (panel.style.display != 'none')
+		playerStep(0)
+}
+
+// Updates the player after a change in the view options
+function refreshPlayer()
+{
+	if (player.graph && document.getElementById('player').style.display != 'none')
+		playerStep(0)
+}
+
+function playerStep(delta)
+{
+	const db = player.graph.db
+	var last = db.invariant ? db.path.length : Infinity
+
+	player.position = Math.min(Math.max(player.position + delta, 0), last)
+
+	var position = player.position
+	var stateNr = playerState(position)
+	var state = db.states[stateNr]
+
+	// Position within the path or the cycle
+	var where
+
+	if (position < db.path.length)
+		where = `path ${position + 1} of ${db.path.length}`
+	else if (db.invariant)
+		where = 'state where the invariant fails'
+	else
+	{
+		var offset = position - db.path.length
+		where = `cycle ${offset % db.cycle.length + 1} of ${db.cycle.length}` +
+			(offset >= db.cycle.length ? `, loop ${Math.floor(offset / db.cycle.length) + 1}` : '')
+	}
+
+	document.getElementById('player-position').textContent = `State ${stateNr} (${where})`
+	document.getElementById('player-prev').disabled = position == 0
+	document.getElementById('player-next').disabled = position == last
+
+	// Term and strategy continuation
+	var rawTerms = document.getElementById('raw-terms')
+	var showRaw = rawTerms && rawTerms.checked && state.rawTerm
+
+	renderView(document.getElementById('player-term'), showRaw ? state.rawView : state.view)
+	renderView(document.getElementById('player-strat'), state.stratView)
+
+	// Transition to the next state
+	var transitionCell = document.getElementById('player-transition')
+
+	if (position == last)
+		transitionCell.textContent = '—'
+	else
+	{
+		var nextNr = playerState(position + 1)
+		var transition = state.successors.find(tr => tr.target == nextNr)
+
+		transitionCell.textContent = ''
+
+		if (transition)
+		{
+			var label = document.createElement('span')
+			label.textContent = transitionText(transition)
+			addSourceListener(label, transition)
+			transitionCell.appendChild(label)
+			transitionCell.appendChild(document.createTextNode(` → state ${nextNr}`))
+		}
+		else
+			transitionCell.textContent = '—'
+	}
+
+	// Atomic propositions that hold in the state (if they are known)
+	var propsRow = document.getElementById('player-propsRow')
+	var propsCell = document.getElementById('player-props')
+
+	propsCell.textContent = ''
+	propsRow.style.display = state.props ? '' : 'none'
+
+	for (const prop of state.props || [])
+	{
+		var item = document.createElement('span')
+		item.className = prop.holds === null ? 'prop-unknown' : (prop.holds ? 'prop-true' : 'prop-false')
+		item.textContent = prop.name
+		propsCell.appendChild(item)
+	}
+
+	// Highlights the current state in the graph
+	for (const circle of player.graph.querySelectorAll('.current'))
+		circle.classList.remove('current')
+
+	if (player.graph.circles[stateNr])
+		player.graph.circles[stateNr].classList.add('current')
+}
@@ -1,9 +1,16 @@
+function isUploaded(file)
+{
+	return file && file.startsWith('tmp:')
+}
+
 function loadSource()
 {
 	var sourceFile = document.getElementById('sourceFile')
-	document.getElementById('openFile').style.display = 'flex'
+	var openFileDialog = document.getElementById('openFile')
+	openFileDialog.style.display = 'flex'
+	openFileDialog.mode = 'source'
 
-	browseDir(sourceFile.fullPath ? sourceFile.fullPath : '', 'source')
+	browseDir(sourceFile.fullPath && !isUploaded(sourceFile.fullPath) ? sourceFile.fullPath : '', 'source')
 }
 
 function closeOpenFileDialog()
@@ -48,6 +55,63 @@ function browseDir(dir, mode)
 	request.send(question)
 }
 
+function uploadFile(input)
+{
+	const request = new XMLHttpRequest()
+	const mode = document.getElementById('openFile').mode
+
+	request.onreadystatechange = function()
+	{
+		if (this.readyState != XMLHttpRequest.DONE)
+			return
+
+		if (this.status != 200)
+		{
+			alert('The file cannot be uploaded: ' + this.responseText)
+			return
+		}
+
+		var listing = JSON.parse(this.responseText)
+
+		if (listing.files.length == 0)
+			alert('The uploaded bundle does not contain any Maude file.')
+
+		else if (listing.files.length == 1)
+			openFile(listing.files[0], mode)
+
+		// The main file of a bundle is chosen by the user
+		else
+		{
+			var fileList = document.getElementById('of-fileList')
+
+			// Names come from the archive, so they are not written as HTML
+			fileList.textContent = ''
+
+			for (const file of listing.files)
+			{
+				var item = document.createElement('li')
+
+				item.className = 'of-item'
+				item.textContent = file.split('/').slice(2).join('/')
+				item.addEventListener('click', () => openFile(file, mode))
+				fileList.appendChild(item)
+			}
+		}
+	}
+
+	if (input.files.length == 0)
+		return
+
+	var question = new FormData()
+
+	question.append('mode', mode)
+	question.append('file', input.files[0])
+	input.value = ''
+
+	request.open('post', 'upload')
+	request.send(question)
+}
+
 function openFile(file, mode)
 {
 	closeOpenFileDialog()
@@ -67,21 +131,68 @@ function openFile(file, mode)
 		dumpfile.value = file
 		form.submit()
 	}
+	else if (mode == 'simplifier')
+		document.getElementById('simp-file').value = file
 }
 
 function buttonToggle()
 {
+	const listing = document.getElementById('description').db
+
+	// Parameterized modules must be instantiated to be checked
+	const instantiated = listing && (listing.params.length == 0 || listing.instance != '')
+
+	document.getElementById('sim-run').disabled = document.getElementById('initial').value == ''
+		|| document.getElementById('module').disabled
+
+	document.getElementById('invariant').disabled = document.getElementById('initial').value == ''
+		|| document.getElementById('formula').value == ''
+		|| document.getElementById('module').disabled
+		|| !instantiated
+
 	document.getElementById('send').disabled = document.getElementById('initial').value == ''
 		|| document.getElementById('formula').value == ''
 		|| document.getElementById('strategy').value == ''
 		|| document.getElementById('module').disabled
-		|| !document.getElementById('description').db.valid
+		|| !instantiated
+		|| !listing.valid
+}
+
+function selectedModule()
+{
+	const listing = document.getElementById('description').db
+
+	// The instance of a parameterized module, if any
+	if (listing && listing.instance != '')
+		return listing.instance
+
+	return document.getElementById('module').value
+}
+
+function fillModules(modules)
+{
+	const smodule = document.getElementById('module')
+
+	// Discard modules from previous files
+	smodule.options.length = 0
+
+	for (module of modules)
+		if (module.type != 'fmod' && module.type != 'fth')
+		{
+			var option = new Option(module.name + ' (' + module.type + ')', module.name)
+			smodule.options.add(option)
+		}
+
+	// Update graphical components
+	smodule.selectedIndex = smodule.options.length - 1
+	smodule.disabled = false
+	buttonToggle()
+	loadModule()
 }
 
 function loadSourceModules(file)
 {
 	const request = new XMLHttpRequest()
-	const smodule = document.getElementById('module')
 
 	request.onreadystatechange = function()
 	{
@@ -89,23 +200,13 @@ function loadSourceModules(file)
 		{
 			var listing = JSON.parse(this.responseText);
 
-			for (module of listing.modules)
-				if (module.type != 'fmod' && module.type != 'fth')
-				{
-					var option = new Option(module.name + ' (' + module.type + ')', module.name)
-					smodule.options.add(option)
-				}
-
-			// Update graphical components
-			smodule.selectedIndex = smodule.options.length - 1
-			smodule.disabled = false
-			buttonToggle()
-			loadModule()
+			fillModules(listing.modules)
+			document.getElementById('sourceFileEdit').disabled = false
 		}
 	}
 
 	// Discard modules from previous files
-	smodule.options.length = 0
+	document.getElementById('module').options.length = 0
 
 	var question = new FormData()
 	question.append('question', 'sourceinfo')
@@ -114,6 +215,144 @@ function loadSourceModules(file)
 	request.send(question)
 }
 
+function editSource()
+{
+	const request = new XMLHttpRequest()
+	const sourceFile = document.getElementById('sourceFile')
+
+	request.onreadystatechange = function()
+	{
+		if (this.readyState == XMLHttpRequest.DONE && this.status == 200)
+		{
+			document.getElementById('ed-text').value = this.responseText
+			document.getElementById('ed-fileName').innerText = sourceFile.fullPath
+			document.getElementById('editor').style.display = 'flex'
+			showMessages([])
+		}
+	}
+
+	var question = new FormData()
+	question.append('question', 'readsource')
+	question.append('url', sourceFile.fullPath)
+	request.open('post', 'ask')
+	request.send(question)
+}
+
+function closeEditor()
+{
+	document.getElementById('editor').style.display = 'none'
+}
+
+function saveSource()
+{
+	const request = new XMLHttpRequest()
+	const sourceFile = document.getElementById('sourceFile')
+	const saveButton = document.getElementById('ed-save')
+
+	request.onreadystatechange = function()
+	{
+		if (this.readyState != XMLHttpRequest.DONE)
+			return
+
+		saveButton.disabled = false
+
+		if (this.status != 200)
+		{
+			alert('The file cannot be saved: ' + this.responseText)
+			return
+		}
+
+		var listing = JSON.parse(this.responseText)
+
+		fillModules(listing.modules)
+		showMessages(listing.messages)
+	}
+
+	saveButton.disabled = true
+
+	var question = new FormData()
+	question.append('question', 'savesource')
+	question.append('url', sourceFile.fullPath)
+	question.append('content', document.getElementById('ed-text').value)
+	request.open('post', 'ask')
+	request.send(question)
+}
+
+function showMessages(messages)
+{
+	const editor = document.getElementById('editor')
+	const list = document.getElementById('ed-messages')
+	const fileName = document.getElementById('sourceFile').fullPath.split('/').pop()
+
+	// Messages are tied to lines when they refer to the edited file
+	editor.marks = new Map()
+	list.innerHTML = ''
+
+	for (msg of messages)
+	{
+		var item = document.createElement('li')
+		var inFile = msg.line > 0 && msg.file.split(/[\\/]/).pop() == fileName
+
+		item.className = 'ed-' + msg.level.toLowerCase()
+
+		if (inFile)
+		{
+			editor.marks.set(msg.line, (editor.marks.has(msg.line) ? editor.marks.get(msg.line) + '\n' : '') + msg.text)
+			item.onclick = selectLine.bind(null, msg.line)
+			item.innerText = `Line ${msg.line}: ${msg.text}`
+		}
+		else
+			item.innerText = (msg.file ? `${msg.file}, line ${msg.line}: ` : '') + msg.text
+
+		list.appendChild(item)
+	}
+
+	updateGutter()
+}
+
+function updateGutter()
+{
+	const marks = document.getElementById('editor').marks
+	const gutter = document.getElementById('ed-gutter')
+	const nrLines = document.getElementById('ed-text').value.split('\n').length
+
+	gutter.innerHTML = ''
+
+	for (var line = 1; line <= nrLines; line++)
+	{
+		var number = document.createElement('div')
+		number.innerText = line
+
+		if (marks && marks.has(line))
+		{
+			number.className = 'ed-mark'
+			number.title = marks.get(line)
+		}
+
+		gutter.appendChild(number)
+	}
+
+	syncGutter()
+}
+
+function syncGutter()
+{
+	document.getElementById('ed-gutter').scrollTop = document.getElementById('ed-text').scrollTop
+}
+
+function selectLine(line)
+{
+	const text = document.getElementById('ed-text')
+	const lines = text.value.split('\n')
+
+	var start = 0
+	for (var i = 0; i < line - 1 && i < lines.length; i++)
+		start += lines[i].length + 1
+
+	text.focus()
+	text.setSelectionRange(start, start + (lines[line - 1] || '').length)
+}
+
 function addPropToFormula(prop)
 {
 	document.getElementById('formula').value += prop
@@ -141,7 +380,21 @@ function formatSignature(signature)
 	return displayName
 }
 
-function loadModule()
+function instantiateModule()
+{
+	const views = Array.from(document.querySelectorAll('#description .viewsel')).map(sel => sel.value)
+
+	// The instance is only built when all parameters have a view
+	if (views.includes(''))
+	{
+		document.getElementById('description').db.instance = ''
+		buttonToggle()
+	}
+	else
+		loadModule(views)
+}
+
+function loadModule(views)
 {
 	const request = new XMLHttpRequest()
 	const currentModule = document.getElementById('module').value
@@ -171,17 +424,35 @@ function loadModule()
 
 			text = `<span style="font-size: 110%;">${text} <code>${currentModule}`
 
-			// Adds theory parameters
+			// Adds theory parameters with a choice of views for each one
 			if (listing.params.length > 0)
 			{
-				text += '{' + listing.params[0]
-				for (i = 0; i < listing.params.length; i++)
-					text += ',' + listing.params[i]
+				text += '{'
+
+				for (var i = 0; i < listing.params.length; i++)
+				{
+					text += (i > 0 ? ', ' : '') + '<select class="viewsel" onchange="instantiateModule()">'
+						+ `<option value="">${listing.params[i]}</option>`
+
+					for (view of listing.views[i])
+						text += `<option${views && views[i] == view ? ' selected' : ''}>${view}</option>`
+
+					text += '</select>'
+				}
+
 				text += '}'
 			}
 
 			text += '</code></span>'
 
+			if (listing.badInstance)
+			{
+				text += '<p>The module cannot be instantiated with these views.</p>'
+					+ '<ul id="msg-instance" class="field-messages"></ul>'
+			}
+			else if (listing.params.length > 0 && listing.instance == '')
+				text += '<p>Choose a view for each parameter to check an instance of the module.</p>'
+
 			// If the module is valid for model checking, shows
 			// some relevant information about it
 			if (listing.valid)
@@ -229,6 +500,9 @@ function loadModule()
 
 			buttonToggle()
 			description.innerHTML = text
+
+			if (listing.badInstance)
+				listMessages(document.getElementById('msg-instance'), listing.messages)
 		}
 	}
 
@@ -237,38 +511,194 @@ function loadModule()
 
 	var question = new FormData()
 	question.append('question', 'modinfo')
-	question.append('mod', currentModule)
+	question.append('mod', views ? `${currentModule}{${views.join(', ')}}` : currentModule)
 	request.open('post', 'ask')
 	request.send(question)
 }
 
+function simulateCommandChanged()
+{
+	const command = document.getElementById('sim-command').value
+	const depthLabel = document.getElementById('sim-depthLabel')
+	const hasDepth = command == 'search' || command == 'frew'
+
+	document.getElementById('sim-search').style.display = command == 'search' ? 'inline' : 'none'
+	document.getElementById('sim-boundLabel').innerText = command == 'rew' || command == 'frew' ? 'Bound:' : 'Solutions:'
+	depthLabel.innerText = command == 'frew' ? 'Gas:' : 'Depth:'
+	depthLabel.style.display = hasDepth ? 'inline' : 'none'
+	document.getElementById('sim-depth').style.display = hasDepth ? 'inline' : 'none'
+}
+
+function simulate()
+{
+	const request = new XMLHttpRequest()
+	const command = document.getElementById('sim-command').value
+	const status = document.getElementById('sim-status')
+	const results = document.getElementById('sim-results')
+	const stopButton = document.getElementById('sim-stop')
+
+	request.onreadystatechange = function()
+	{
+		if (this.readyState != XMLHttpRequest.DONE)
+			return
+
+		stopButton.disabled = true
+		stopButton.request = null
+
+		if (this.status == 200)
+		{
+			var result = JSON.parse(this.responseText)
+
+			listMessages(document.getElementById('sim-messages'), result.messages)
+
+			if (!result.ok)
+				status.innerText = 'Maude could not execute the command (the input may not be valid).'
+			else
+			{
+				status.innerText = result.solutions.length + ' solution(s)'
+					+ (result.complete ? '' : ' (stopped by the bounds)')
+					+ (result.stats.available ? ', ' + formatStats(result.stats) : '')
+				renderSolutions(results, result.solutions)
+			}
+		}
+		else if (this.status != 0)
+			status.innerText = this.responseText
+	}
+
+	results.innerHTML = ''
+	document.getElementById('sim-messages').innerHTML = ''
+	status.innerText = 'Maude is working...'
+
+	var question = new FormData()
+
+	question.append('question', 'simulate')
+	question.append('command', command)
+	question.append('mod', selectedModule())
+	question.append('initial', document.getElementById('initial').value)
+	question.append('strategy', document.getElementById('strategy').value)
+	question.append('arrow', document.getElementById('sim-arrow').value)
+	question.append('pattern', document.getElementById('sim-pattern').value)
+	question.append('condition', document.getElementById('sim-condition').value)
+	question.append('bound', document.getElementById('sim-bound').value)
+	question.append('depth', document.getElementById('sim-depth').value)
+
+	stopButton.disabled = false
+	stopButton.request = request
+
+	request.open('post', 'ask')
+	request.send(question)
+}
+
+function stopSimulation()
+{
+	const stopButton = document.getElementById('sim-stop')
+
+	// Aborting the request interrupts Maude in the server
+	if (stopButton.request)
+	{
+		stopButton.request.abort()
+		document.getElementById('sim-status').innerText = 'Simulation stopped.'
+	}
+}
+
+function formatStats(stats)
+{
+	// Durations are given in nanoseconds
+	var text = `${stats.rewrites} rewrites in ${stats.cpuTime / 1e6} ms cpu (${stats.realTime / 1e6} ms real)`
+
+	if (stats.states > 0)
+		text = `${stats.states} states, ` + text
+
+	return text
+}
+
+function renderSolutions(list, solutions)
+{
+	for (const solution of solutions)
+	{
+		var item = document.createElement('li')
+		var text = ''
+
+		if (solution.term)
+			text += `${solution.type}: ${solution.term}\n`
+
+		for (const binding of solution.substitution)
+			text += `${binding.variable} --> ${binding.value}\n`
+
+		if (!solution.term && solution.substitution.length == 0)
+			text += 'empty substitution\n'
+
+		item.innerText = text
+
+		if (solution.stats.available)
+		{
+			var stats = document.createElement('span')
+			stats.className = 'sim-stats'
+			stats.innerText = (solution.state >= 0 ? `state ${solution.state}, ` : '') + formatStats(solution.stats)
+			item.appendChild(stats)
+		}
+
+		list.appendChild(item)
+	}
+}
+
 function loadDump()
 {
 	var openFileDialog = document.getElementById('openFile')
 	var dumpfile = document.getElementById('dumpfile').value
 	openFileDialog.style.display = 'flex'
+	openFileDialog.mode = 'dump'
 
-	browseDir(dumpfile ? dumpfile : '', 'dump')
+	browseDir(dumpfile && !isUploaded(dumpfile) ? dumpfile : '', 'dump')
 }
 
-function modelcheck()
+function listMessages(list, messages)
 {
-	var request = new XMLHttpRequest()
-	var textArea = document.getElementById('source')
+	list.innerHTML = ''
 
-	request.onreadystatechange = function()
+	for (message of messages || [])
+	{
+		var item = document.createElement('li')
+		item.innerText = (message.level ? message.level + ': ' : '') + message.text
+		list.appendChild(item)
+	}
+}
+
+function showFieldMessages(field, messages)
+{
+	const input = document.getElementById(field)
+
+	if (messages && messages.length > 0)
+		input.classList.add('field-error')
+	else
+		input.classList.remove('field-error')
+
+	listMessages(document.getElementById('msg-' + field), messages)
+}
+
+function checkResponse(invariant)
+{
+	return function()
 	{
 		if (this.readyState == XMLHttpRequest.DONE && this.status == 200)
 		{
 			var listing = JSON.parse(this.responseText)
 			var errbar = document.getElementById('errorbar')
 
+			// Maude messages are shown next to the offending field
+			const fields = ['initial', 'formula', 'strategy']
+
+			for (var i = 0; i < fields.length; i++)
+				showFieldMessages(fields[i], listing.status == i + 1 ? listing.messages : [])
+
 			switch (listing.status)
 			{
 				case 0 : errbar.innerText = ''; break
 				case 1 : errbar.innerText = 'Syntax error at the initial term'; break
-				case 2 : errbar.innerText = 'Syntax error at the LTL formula'; break
-				case 3 : errbar.innerText = 'Syntax error at strategy expression'; break
+				case 2 : errbar.innerText = invariant ? 'The formula is not an atomic proposition'
+					: 'Syntax error at the LTL formula'; break
+				case 3 : errbar.innerText = listing.pos < 0 ? 'Error in the strategy expression'
+					: 'Syntax error at strategy expression'; break
 				case 4 :
 					var opaques = document.getElementById('opaques').value.split(' ').filter(Boolean)
 					errbar.innerHTML = `Unknown strategy <i>${opaques[listing.pos]}</i> in the opaque list`
@@ -280,11 +710,36 @@ function modelcheck()
 				location.reload()
 		}
 	}
+}
+
+function checkInvariant()
+{
+	var request = new XMLHttpRequest()
+
+	request.onreadystatechange = checkResponse(true)
+
+	var question = new FormData()
+
+	question.append('question', 'invariant')
+	question.append('mod', selectedModule())
+	question.append('initial', document.getElementById('initial').value)
+	question.append('formula', document.getElementById('formula').value)
+	question.append('strategy', document.getElementById('strategy').value)
+
+	request.open('post', 'ask')
+	request.send(question)
+}
+
+function modelcheck()
+{
+	var request = new XMLHttpRequest()
+
+	request.onreadystatechange = checkResponse(false)
 
 	var question = new FormData()
 
 	question.append('question', 'modelcheck')
-	question.append('mod', document.getElementById('module').value)
+	question.append('mod', selectedModule())
 	question.append('initial', document.getElementById('initial').value)
 	question.append('formula', document.getElementById('formula').value)
 	question.append('strategy', document.getElementById('strategy').value)
@@ -294,32 +749,332 @@ function modelcheck()
 	request.send(question)
 }
 
+function viewDump(url)
+{
+	var form = document.createElement('form')
+	var input = document.createElement('input')
+
+	form.action = '/'
+	form.method = 'post'
+
+	input.name = 'dumpfile'
+	input.type = 'hidden'
+	input.value = url
+
+	form.appendChild(input)
+	document.body.appendChild(form)
+	form.submit()
+}
+
 function waitModelChecker() {
 	var request = new XMLHttpRequest()
 
+	request.onreadystatechange = function()
+	{
+		if (this.readyState == XMLHttpRequest.DONE)
+		{
+			if (this.status == 200)
+				viewDump(this.responseText.trim())
+			else
+			{
+				alert(this.responseText)
+				location.href = '/'
+			}
+		}
+	}
+
+	var question = new FormData()
+
+	question.append('question', 'wait')
+	request.open('post', 'ask')
+	request.send(question)
+}
+
+function loadHistory()
+{
+	const request = new XMLHttpRequest()
+
 	request.onreadystatechange = function()
 	{
 		if (this.readyState == XMLHttpRequest.DONE && this.status == 200)
 		{
-			var form = document.createElement('form')
-			var input = document.createElement('input')
+			var listing = JSON.parse(this.responseText)
+
+			document.getElementById('hs-disabled').style.display = listing.enabled ? 'none' : 'block'
+			document.getElementById('hs-table').style.display = listing.enabled ? 'table' : 'none'
+			document.getElementById('hs-runs').db = listing.runs
 
-			form.action = '/'
-			form.method = 'post'
+			renderHistory(listing.runs)
+		}
+	}
 
-			input.name = 'dumpfile'
-			input.type = 'hidden'
-			input.value = this.responseText.trim()
+	var question = new FormData()
+	question.append('question', 'history')
+	request.open('post', 'ask')
+	request.send(question)
+}
 
-			form.appendChild(input)
-			document.body.appendChild(form)
-			form.submit()
+function historyCell(row, content)
+{
+	var cell = row.insertCell()
+
+	if (content instanceof Node)
+		cell.appendChild(content)
+	else
+		cell.innerText = content
+
+	return cell
+}
+
+function historyAction(text, action)
+{
+	var link = document.createElement('a')
+	link.href = '#'
+	link.innerText = text
+	link.onclick = function (event) { event.preventDefault() ; action() }
+	return link
+}
+
+function renderHistory(runs)
+{
+	const tbody = document.getElementById('hs-runs')
+
+	tbody.innerHTML = ''
+
+	for (let run of runs)
+	{
+		var row = tbody.insertRow()
+
+		var check = document.createElement('input')
+		check.type = 'checkbox'
+		check.value = run.id
+		historyCell(row, check)
+
+		historyCell(row, new Date(run.endTime).toLocaleString())
+
+		var name = document.createElement('input')
+		name.type = 'text'
+		name.value = run.name
+		name.placeholder = run.id
+		historyCell(row, name)
+
+		var tags = document.createElement('input')
+		tags.type = 'text'
+		tags.value = run.tags.join(', ')
+		historyCell(row, tags)
+
+		name.onchange = tags.onchange = updateRun.bind(null, run, name, tags)
+
+		historyCell(row, run.input.module).title = run.input.file
+		historyCell(row, run.input.formula)
+		historyCell(row, run.input.strategy)
+		var verdict = historyCell(row, run.holds ? 'holds' : 'fails')
+		verdict.className = run.holds ? 'hs-holds' : 'hs-fails'
+
+		// Durations are given in nanoseconds
+		if (run.stats && run.stats.available)
+			verdict.title = `${run.stats.rewrites} rewrites in ${run.stats.cpuTime / 1e6} ms cpu (${run.stats.realTime / 1e6} ms real)`
+
+		var actions = document.createElement('span')
+		actions.appendChild(historyAction('Open', viewDump.bind(null, 'run:' + run.id)))
+		actions.appendChild(document.createTextNode(' · '))
+		actions.appendChild(historyAction('Re-run', rerun.bind(null, run.id)))
+		actions.appendChild(document.createTextNode(' · '))
+		actions.appendChild(historyAction('Delete', deleteRun.bind(null, run)))
+		historyCell(row, actions)
+	}
+}
+
+function updateRun(run, name, tags)
+{
+	const request = new XMLHttpRequest()
+
+	request.onreadystatechange = function()
+	{
+		if (this.readyState == XMLHttpRequest.DONE && this.status == 200)
+		{
+			var updated = JSON.parse(this.responseText)
+			run.name = updated.name
+			run.tags = updated.tags
+			tags.value = run.tags.join(', ')
 		}
 	}
 
 	var question = new FormData()
+	question.append('question', 'updaterun')
+	question.append('id', run.id)
+	question.append('name', name.value)
+	question.append('tags', tags.value)
+	request.open('post', 'ask')
+	request.send(question)
+}
+
+function deleteRun(run)
+{
+	if (!confirm(`Delete the run ${run.name ? run.name : run.id}?`))
+		return
+
+	const request = new XMLHttpRequest()
+
+	request.onreadystatechange = function()
+	{
+		if (this.readyState == XMLHttpRequest.DONE && this.status == 200)
+			loadHistory()
+	}
+
+	var question = new FormData()
+	question.append('question', 'deleterun')
+	question.append('id', run.id)
+	request.open('post', 'ask')
+	request.send(question)
+}
+
+function rerun(id)
+{
+	const request = new XMLHttpRequest()
+
+	request.onreadystatechange = function()
+	{
+		if (this.readyState != XMLHttpRequest.DONE)
+			return
+
+		if (this.status != 200)
+		{
+			alert('The run cannot be repeated: ' + this.responseText)
+			return
+		}
+
+		var listing = JSON.parse(this.responseText)
+
+		if (listing.status == 0)
+			location = '/'
+		else
+			alert('The input data is no longer valid for the current source file.')
+	}
+
+	var question = new FormData()
+	question.append('question', 'rerun')
+	question.append('id', id)
+	request.open('post', 'ask')
+	request.send(question)
+}
+
+function compareRuns()
+{
+	const runs = document.getElementById('hs-runs').db
+	const compare = document.getElementById('hs-compare')
+
+	var selected = Array.from(document.querySelectorAll('#hs-runs input[type=checkbox]:checked'))
+		.map(check => runs.find(run => run.id == check.value))
+
+	compare.innerHTML = ''
+
+	if (selected.length < 2)
+	{
+		alert('Select at least two runs to compare.')
+		return
+	}
+
+	var fields = [
+		['Name', run => run.name],
+		['Tags', run => run.tags.join(', ')],
+		['File', run => run.input.file],
+		['Module', run => run.input.module],
+		['Initial term', run => run.input.initial],
+		['LTL formula', run => run.input.formula],
+		['Strategy', run => run.input.strategy],
+		['Opaque strategies', run => run.input.opaques],
+		['Result', run => run.holds ? 'holds' : 'fails'],
+		['Number of states', run => run.states],
+		['Counterexample', run => run.holds ? '' : `path of ${run.pathLength} and cycle of ${run.cycleLength} states`],
+		['Duration', run => `${(new Date(run.endTime) - new Date(run.input.startTime)) / 1000} s`],
+	]
+
+	var table = document.createElement('table')
+	var header = table.insertRow()
+
+	historyCell(header, '')
+
+	for (run of selected)
+		historyCell(header, run.id)
+
+	for (field of fields)
+	{
+		var row = table.insertRow()
+		var values = selected.map(field[1])
+
+		historyCell(row, field[0] + ':')
+
+		for (value of values)
+			historyCell(row, value)
+
+		if (values.some(value => value != values[0]))
+			row.className = 'hs-diff'
+	}
+
+	compare.appendChild(table)
+}
+
+function loadSimplifier()
+{
+	const request = new XMLHttpRequest()
+
+	request.onreadystatechange = function()
+	{
+		if (this.readyState == XMLHttpRequest.DONE && this.status == 200)
+			showSimplifier(JSON.parse(this.responseText))
+	}
+
+	var question = new FormData()
+
+	question.append('question', 'simplifier')
+
+	request.open('post', 'ask')
+	request.send(question)
+}
+
+function showSimplifier(info)
+{
+	document.getElementById('simp-op').value = info.op
+	document.getElementById('simp-file').value = info.file
+	document.getElementById('simp-status').innerText = info.active ? 'Active' : 'Disabled'
+}
+
+function chooseSimplifierFile()
+{
+	var simpFile = document.getElementById('simp-file').value
+	var openFileDialog = document.getElementById('openFile')
+	openFileDialog.style.display = 'flex'
+	openFileDialog.mode = 'simplifier'
+
+	browseDir(simpFile && !isUploaded(simpFile) ? simpFile : '', 'simplifier')
+}
+
+// Sets the simplifier of the result terms (an empty operator disables it)
+// and reloads the page if requested
+function setSimplifier(reload)
+{
+	const request = new XMLHttpRequest()
+
+	request.onreadystatechange = function()
+	{
+		if (this.readyState != XMLHttpRequest.DONE)
+			return
+
+		if (this.status != 200)
+			alert('The simplifier cannot be set: ' + this.responseText)
+		else if (reload)
+			location.reload()
+		else
+			showSimplifier(JSON.parse(this.responseText))
+	}
+
+	var question = new FormData()
+
+	question.append('question', 'setsimplifier')
+	question.append('op', document.getElementById('simp-op').value.trim())
+	question.append('file', document.getElementById('simp-file').value.trim())
 
-	question.append('question', 'wait')
 	request.open('post', 'ask')
 	request.send(question)
 }
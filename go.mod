@@ -1 +1,3 @@
 module github.com/ningit/smcview
+
+go 1.27.1
@@ -32,12 +32,18 @@ type Grapher struct {
 	gopt       GraphOpt
 	seenTerms  map[int32]struct{}
 	seenStrats map[int32]struct{}
-	simplifier util.TermSimplifier
+	terms      *util.TermCache
 }
 
 // MakeGrapher initializes a grapher.
 func MakeGrapher(gopt GraphOpt, termSimplifier util.TermSimplifier) Grapher {
-	return Grapher{gopt, make(map[int32]struct{}), make(map[int32]struct{}), termSimplifier}
+	return MakeCachedGrapher(gopt, util.NewTermCache(termSimplifier))
+}
+
+// MakeCachedGrapher initializes a grapher that takes the simplified terms
+// from the given cache (which must belong to the dumps being graphed).
+func MakeCachedGrapher(gopt GraphOpt, terms *util.TermCache) Grapher {
+	return Grapher{gopt, make(map[int32]struct{}), make(map[int32]struct{}), terms}
 }
 
 // Clean removes the grapher cache and returns the grapher to its original state.
@@ -49,8 +55,17 @@ func (g *Grapher) Clean() {
 func (g *Grapher) generateLegend(writer io.Writer, dump smcdump.SmcDump) {
 	io.WriteString(writer, "\n\tlegendTerms "+legendBegin)
 
+	// Terms are simplified all together in advance
+	var keys = make([]int32, 0, len(g.seenTerms))
+
 	for key, _ := range g.seenTerms {
-		fmt.Fprintf(writer, legendElem, key, util.CleanHtmlString(g.simplifier.Simplify(dump.GetString(key))))
+		keys = append(keys, key)
+	}
+
+	g.terms.Prefetch(dump, keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(writer, legendElem, key, util.CleanHtmlString(g.terms.Term(dump, key)))
 	}
 
 	io.WriteString(writer, legendEnd+"\n\tlegendStrats "+legendBegin)
@@ -70,6 +85,16 @@ func (g *Grapher) GenerateDot(writer io.Writer, dump smcdump.SmcDump) {
 
 	var nrStates = dump.NumberOfStates()
 
+	if g.gopt == Term {
+		var states = make([]int32, nrStates)
+
+		for i := range states {
+			states[i] = int32(i)
+		}
+
+		g.prefetchTerms(dump, states)
+	}
+
 	for i := 0; i < nrStates; i++ {
 		g.graphState(writer, dump, int32(i), -1)
 	}
@@ -93,6 +118,10 @@ func (g *Grapher) GenerateCounterDot(writer io.Writer, dump smcdump.SmcDump) {
 	var pathLength = len(path)
 	var cycleLength = len(cycle)
 
+	if g.gopt == Term {
+		g.prefetchTerms(dump, append(append([]int32(nil), path...), cycle...))
+	}
+
 	for index, nodeNr := range dump.Path() {
 		var targetNr int32
 
@@ -124,6 +153,17 @@ func (g *Grapher) GenerateCounterDot(writer io.Writer, dump smcdump.SmcDump) {
 	io.WriteString(writer, "}\n")
 }
 
+// prefetchTerms simplifies all together the terms of the given states.
+func (g *Grapher) prefetchTerms(dump smcdump.SmcDump, states []int32) {
+	var terms = make([]int32, len(states))
+
+	for i, stateNr := range states {
+		terms[i] = dump.State(stateNr).Term
+	}
+
+	g.terms.Prefetch(dump, terms)
+}
+
 func (g *Grapher) graphState(writer io.Writer, dump smcdump.SmcDump, stateNr, targetNr int32) {
 	var state = dump.State(stateNr)
 
@@ -137,7 +177,7 @@ func (g *Grapher) graphState(writer io.Writer, dump smcdump.SmcDump, stateNr, ta
 	case Short:
 		fmt.Fprintf(writer, "(%d, %d)\"", state.Term, state.Strategy)
 	case Term:
-		io.WriteString(writer, util.CleanEscapeString(g.simplifier.Simplify(dump.GetString(state.Term)))+"\"")
+		io.WriteString(writer, util.CleanEscapeString(g.terms.Term(dump, state.Term))+"\"")
 	case Strat:
 		io.WriteString(writer, util.CleanEscapeString(dump.GetString(state.Strategy))+"\"")
 	}
@@ -3,6 +3,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"github.com/ningit/smcview/grapher"
@@ -16,6 +17,8 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"text/tabwriter"
+	"time"
 )
 
 // Strings constants used by the command line interface
@@ -29,29 +32,42 @@ The argument must be provided after all flags. Use -help to get information abou
 Its path can be specified using the -maudecmd flag or the SMAUDE environment variable.`
 )
 
-func underRoot(rootpath, otherpath string) bool {
-	// Paths are assumed to be absolute and cleaned
-	return strings.HasPrefix(otherpath, rootpath)
-}
-
-func processDump(fpath, graphMode, simplifierOpName string, maudec *maude.Client, toPdf bool) {
+func processDump(fpath, graphMode string, simplifierSpecs []util.SimplifierSpec, model modelOptions, maudePath string, toPdf bool) {
 	var dump, err = smcdump.Read(fpath)
 	if dump == nil {
 		log.Fatal(err)
 	}
 
 	// Creates a simplifier for the state terms
-	// (a dummy one if simplifierOpName is empty)
-	var simplifier = util.CreateSimplifier(simplifierOpName, maudec)
+	// (a dummy one if simplifierSpecs is empty)
+	var simplifier = util.CreateSimplifier(simplifierSpecs, maudePath)
+	defer simplifier.Close()
 
 	// Shows the basic information about the dump
 	fmt.Printf("     LTL formula:  %s\n", dump.LtlFormula())
 	fmt.Printf("    Initial term:  %s\n", simplifier.Simplify(dump.InitialTerm()))
 	fmt.Printf("Number of states:  %d\n", dump.NumberOfStates())
 	fmt.Printf("           Holds:  %v\n", dump.PropertyHolds())
+
+	// Shows how the dump was generated, if recorded
+	var provenance, _ = smcdump.ReadProvenance(fpath)
+
+	if provenance != nil {
+		printProvenance(provenance)
+	}
+
 	if !dump.PropertyHolds() {
 		fmt.Printf("            Path:  %v\n", dump.Path())
 		fmt.Printf("           Cycle:  %v\n", dump.Cycle())
+
+		if model.file != "" {
+			// The model may not be the one where the dump was generated
+			if hash, _ := smcdump.HashFile(model.file); provenance != nil && hash != provenance.SourceHash {
+				log.Println("the model file differs from the one where the dump was generated")
+			}
+
+			inspectModel(dump, model, maudePath)
+		}
 	}
 
 	// Parses graph options and constructs a grapher with them
@@ -127,6 +143,193 @@ func processDump(fpath, graphMode, simplifierOpName string, maudec *maude.Client
 	dump.Close()
 }
 
+// printProvenance prints how a dump was generated.
+func printProvenance(provenance *smcdump.Provenance) {
+	var changed = ""
+
+	// The web URLs of the sources are paths unless the web interface was
+	// confined to a root directory
+	if provenance.SourceChanged(provenance.Source) {
+		changed = " (changed since)"
+	}
+
+	fmt.Printf("          Source:  %s%s\n", provenance.Source, changed)
+	fmt.Printf("     Source hash:  %s\n", provenance.SourceHash)
+	fmt.Printf("          Module:  %s\n", provenance.Module)
+	if provenance.Strategy != "" {
+		fmt.Printf("        Strategy:  %s\n", provenance.Strategy)
+	}
+	if provenance.Opaques != "" {
+		fmt.Printf("         Opaques:  %s\n", provenance.Opaques)
+	}
+	if provenance.MaudeVersion != "" {
+		fmt.Printf("   Maude version:  %s\n", provenance.MaudeVersion)
+	}
+	fmt.Printf("       Generated:  %s in %v\n", provenance.EndTime.Format("2006-01-02 15:04:05"), provenance.Duration())
+	if provenance.Rewrites > 0 {
+		fmt.Printf("      Statistics:  %d rewrites in %v cpu, %v real\n", provenance.Rewrites, provenance.CpuTime, provenance.RealTime)
+	}
+}
+
+// modelOptions gathers the command line options about the Maude model
+// where the dump was generated.
+type modelOptions struct {
+	file, module string
+	// Whether the statements behind the transitions are printed
+	rules        bool
+}
+
+// inspectModel loads the model where the dump was generated to print the
+// truth table of the atomic propositions of the formula in the states of the
+// counterexample and, if requested, the statements behind its transitions.
+// The model is the given module of the given file (or its last module).
+func inspectModel(dump smcdump.SmcDump, opts modelOptions, maudePath string) {
+	var ctx = context.Background()
+
+	pool, err := maude.NewPool(ctx, maudePath, 1, []string{opts.file}, nil)
+	if err != nil {
+		log.Println("cannot load the model:", err)
+		return
+	}
+
+	defer pool.Close(ctx)
+
+	maudec, err := pool.Get(ctx)
+	if err != nil {
+		log.Println("cannot load the model:", err)
+		return
+	}
+
+	defer pool.Put(maudec, nil)
+
+	var module = opts.module
+
+	if module == "" {
+		if module, err = maudec.CurrentModuleName(ctx); err != nil {
+			log.Println("cannot load the model:", err)
+			return
+		}
+	}
+
+	printProps(ctx, maudec, module, dump)
+
+	if opts.rules {
+		printTransitionSources(ctx, maudec, module, dump)
+	}
+}
+
+// printProps evaluates the atomic propositions of the formula in the states
+// of the counterexample and prints their truth table.
+func printProps(ctx context.Context, maudec *maude.Client, module string, dump smcdump.SmcDump) {
+	// The propositions are evaluated in the path and then in the cycle
+	var pathLength = len(dump.Path())
+	var sequence = append(append([]int32(nil), dump.Path()...), dump.Cycle()...)
+	var terms = make([]string, len(sequence))
+
+	for i, stateNr := range sequence {
+		terms[i] = dump.GetString(dump.State(stateNr).Term)
+	}
+
+	table, err := maudec.EvalProps(ctx, module, dump.LtlFormula(), terms)
+	if err != nil {
+		log.Println("cannot evaluate the atomic propositions:", err)
+		return
+	}
+
+	if table == nil || len(table.Props) == 0 {
+		log.Printf("the atomic propositions of the formula cannot be evaluated in module %s\n", module)
+		return
+	}
+
+	fmt.Printf("\nAtomic propositions (T true, F false, ? unknown; path | cycle):\n")
+
+	var writer = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	fmt.Fprint(writer, "  state")
+
+	for i, stateNr := range sequence {
+		if i == pathLength && i > 0 {
+			fmt.Fprint(writer, "\t|")
+		}
+		fmt.Fprintf(writer, "\t%d", stateNr)
+	}
+
+	for j, prop := range table.Props {
+		fmt.Fprintf(writer, "\n  %s", prop)
+
+		for i := range sequence {
+			if i == pathLength && i > 0 {
+				fmt.Fprint(writer, "\t|")
+			}
+
+			switch table.Values[i][j] {
+				case maude.PropTrue  : fmt.Fprint(writer, "\tT")
+				case maude.PropFalse : fmt.Fprint(writer, "\tF")
+				default              : fmt.Fprint(writer, "\t?")
+			}
+		}
+	}
+
+	fmt.Fprintln(writer)
+	writer.Flush()
+}
+
+// printTransitionSources prints the rules and the definitions of the opaque
+// strategies behind the transitions of the counterexample.
+func printTransitionSources(ctx context.Context, maudec *maude.Client, module string, dump smcdump.SmcDump) {
+	var path, cycle = dump.Path(), dump.Cycle()
+
+	if len(cycle) == 0 {
+		return
+	}
+
+	var sequence = append(append([]int32(nil), path...), cycle...)
+	// Transitions already printed
+	var printed = make(map[smcdump.Transition]bool)
+
+	for i, stateNr := range sequence {
+		// The successor of the last state is the first of the cycle
+		var next = cycle[0]
+
+		if i+1 < len(sequence) {
+			next = sequence[i+1]
+		}
+
+		for _, tr := range dump.State(stateNr).Successors {
+			var key = smcdump.Transition{Label: tr.Label, TrType: tr.TrType}
+
+			if tr.Target != next || tr.TrType == smcdump.Idle || printed[key] {
+				continue
+			}
+
+			printed[key] = true
+
+			var label = dump.GetString(tr.Label)
+			var title = label
+
+			if tr.TrType == smcdump.Opaque {
+				title = "opaque(" + label + ")"
+			}
+
+			statements, err := maudec.TransitionSource(ctx, module, label, tr.TrType == smcdump.Opaque)
+			if err != nil {
+				log.Println("cannot obtain the statements behind the transitions:", err)
+				return
+			}
+
+			fmt.Printf("\nTransition %s:\n", title)
+
+			if len(statements) == 0 {
+				fmt.Println("  (not found in module " + module + ")")
+			}
+
+			for _, statement := range statements {
+				fmt.Println("  " + strings.Replace(statement, "\n", "\n  ", -1))
+			}
+		}
+	}
+}
+
 func checkForMaude(maudePath string) (string, string) {
 	var maudeVersion string
 
@@ -148,18 +351,76 @@ func checkForMaude(maudePath string) (string, string) {
 	return maudePath, maudeVersion
 }
 
-func startServer(port int, verbose bool, maudec *maude.Client, address, sourcedir, rootdir string) {
+// simplifierOptions gathers the command line options for the simplifier.
+type simplifierOptions struct {
+	op, file, module, config string
+	maxLength                int
+	timeout                  time.Duration
+}
+
+// simplifierSpecs builds the chain of simplifiers from the configuration
+// file, preceded by the Maude simplifier and followed by the elision given
+// in the command line, if any.
+func simplifierSpecs(opts simplifierOptions) []util.SimplifierSpec {
+	var specs = make([]util.SimplifierSpec, 0)
+
+	if opts.op != "" {
+		// A zero timeout means no limit (a negative one in the specification)
+		var timeout = opts.timeout.Seconds()
+
+		if timeout == 0 {
+			timeout = -1
+		}
+
+		specs = append(specs, util.SimplifierSpec{Type: "maude", File: opts.file,
+			Module: opts.module, Op: opts.op, Timeout: timeout})
+	}
+
+	if opts.config != "" {
+		config, err := util.ReadSimplifierConfig(opts.config)
+		if err != nil {
+			log.Fatal("cannot read the simplifier configuration: ", err)
+		}
+
+		specs = append(specs, config...)
+	}
+
+	if opts.maxLength > 0 {
+		specs = append(specs, util.SimplifierSpec{Type: "elide", MaxLength: opts.maxLength})
+	}
+
+	return specs
+}
+
+// serverOptions gathers the command line options for the web interface.
+type serverOptions struct {
+	port, uploadLimit, poolSize          int
+	verbose, tls                         bool
+	address, sourcedir, rootdir, datadir string
+	auth, passwdFile, certFile, keyFile  string
+}
+
+func startServer(opts serverOptions, simplifiers []util.SimplifierSpec, maudePath string) {
 	// Sets up the web interface by later fixing the port address and
 	// relevant directories
-	var srv = webui.InitWebUi(maudec, assets)
+	var srv = webui.InitWebUi(maudePath, simplifiers, assets)
 	if srv == nil {
 		log.Fatal("the web interface cannot be initializated")
 	}
 
-	srv.Port = port
-	srv.Address = address
+	srv.Port = opts.port
+	srv.Address = opts.address
+	srv.MaxUploadSize = int64(opts.uploadLimit) << 20
+
+	if opts.poolSize < 1 {
+		log.Fatal("the number of Maude interpreters must be positive")
+	}
+
+	srv.PoolSize = opts.poolSize
 
 	// The interface access will be confined to this directory if non-empty
+	var rootdir = opts.rootdir
+
 	if rootdir != "" {
 		fileInfo, _ := os.Stat(rootdir)
 
@@ -174,7 +435,7 @@ func startServer(port int, verbose bool, maudec *maude.Client, address, sourcedi
 
 	// The source dir will be used as initial directory for source files.
 	// It must be inside the root directory in case it was specified.
-	if sourcedir != "" {
+	if sourcedir := opts.sourcedir; sourcedir != "" {
 		fileInfo, _ := os.Stat(sourcedir)
 
 		if fileInfo == nil || !fileInfo.IsDir() {
@@ -183,13 +444,53 @@ func startServer(port int, verbose bool, maudec *maude.Client, address, sourcedi
 
 		sourcedir, _ = filepath.Abs(sourcedir)
 
-		if rootdir != "" && !underRoot(rootdir, sourcedir) {
+		if rootdir != "" && !webui.InsideDir(rootdir, sourcedir) {
 			log.Fatal("source directory is outside root directory")
 		}
 
 		srv.InitialDir = sourcedir
 	}
 
+	// The history of runs is kept in the data directory, if given
+	if datadir := opts.datadir; datadir != "" {
+		if err := os.MkdirAll(datadir, 0755); err != nil {
+			log.Fatal("wrong data directory: ", err)
+		}
+
+		srv.DataDir, _ = filepath.Abs(datadir)
+	}
+
+	// HTTPS with the given certificate or a self-signed one
+	if opts.certFile != "" || opts.keyFile != "" {
+		if opts.certFile == "" || opts.keyFile == "" {
+			log.Fatal("both the certificate and its key must be given")
+		}
+
+		srv.CertFile = opts.certFile
+		srv.KeyFile = opts.keyFile
+		opts.tls = true
+	}
+
+	srv.TLS = opts.tls
+
+	// Authentication method
+	switch opts.auth {
+		case "none"  :
+		case "token" :
+			if err := srv.EnableToken(); err != nil {
+				log.Fatal("cannot generate the access token: ", err)
+			}
+		case "basic" :
+			if opts.passwdFile == "" {
+				log.Fatal("basic authentication requires a password file (-passwdfile)")
+			}
+			if err := srv.EnablePasswords(opts.passwdFile); err != nil {
+				log.Fatal("cannot read the password file: ", err)
+			}
+		default:
+			log.Fatal("unknown authentication method '", opts.auth, "' (none, token or basic expected)")
+	}
+
 	var addressName = srv.Address
 
 	// More user-friendly name
@@ -197,7 +498,7 @@ func startServer(port int, verbose bool, maudec *maude.Client, address, sourcedi
 		addressName = "localhost"
 	}
 
-	fmt.Printf("Listening at http://%s:%d/\n", addressName, srv.Port)
+	fmt.Printf("Listening at %s\n", srv.BaseUrl(addressName))
 
 	srv.Start()
 }
@@ -205,20 +506,38 @@ func startServer(port int, verbose bool, maudec *maude.Client, address, sourcedi
 func main() {
 	// Parses command line arguments
 	var (
-		verbose, graphPdf                                             bool
-		port                                                          int
-		address, maudePath, sourcedir, rootdir, graphMode, simplifier string
+		graphPdf                               bool
+		maudePath, graphMode                   string
+		opts                                   serverOptions
+		simplOpts                              simplifierOptions
+		modelOpts                              modelOptions
 	)
 
-	flag.IntVar(&port, "port", 1234, "server listening `port`")
-	flag.StringVar(&address, "address", "127.0.0.1", "server listening `address`")
-	flag.BoolVar(&verbose, "verbose", false, "show more information")
+	flag.IntVar(&opts.port, "port", 1234, "server listening `port`")
+	flag.StringVar(&opts.address, "address", "127.0.0.1", "server listening `address`")
+	flag.BoolVar(&opts.verbose, "verbose", false, "show more information")
 	flag.StringVar(&maudePath, "maudecmd", "", "maude executable `path`")
-	flag.StringVar(&sourcedir, "sourcedir", "", "initial source `directory`")
-	flag.StringVar(&rootdir, "rootdir", "", "restrict access to the filesystem to a given `directory`")
+	flag.StringVar(&opts.sourcedir, "sourcedir", "", "initial source `directory`")
+	flag.StringVar(&opts.rootdir, "rootdir", "", "restrict access to the filesystem to a given `directory` (required to save sources other than uploaded ones)")
+	flag.StringVar(&opts.datadir, "datadir", "", "`directory` where the history of model checker runs is kept (disabled if empty)")
+	flag.IntVar(&opts.poolSize, "poolsize", 2, "`number` of Maude interpreters kept ready for the loaded source file")
+	flag.IntVar(&opts.uploadLimit, "uploadlimit", 32, "maximum size in `MiB` of the files uploaded through the web interface")
+	flag.BoolVar(&opts.tls, "tls", false, "serve the web interface over HTTPS (with a self-signed certificate unless -cert is given)")
+	flag.StringVar(&opts.certFile, "cert", "", "TLS certificate `file` in PEM format (implies -tls)")
+	flag.StringVar(&opts.keyFile, "key", "", "TLS private key `file` in PEM format")
+	flag.StringVar(&opts.auth, "auth", "none", "authentication `method` for the web interface (among none, token, basic)")
+	flag.StringVar(&opts.passwdFile, "passwdfile", "", "`file` with user:password lines for basic authentication")
 	flag.BoolVar(&graphPdf, "pdf", false, "generate PDF instead of DOT files (GraphViz is required)")
 	flag.StringVar(&graphMode, "gopt", "legend", "choose how state labels are printed in DOT graphs (among legend, term, strat, short)")
-	flag.StringVar(&simplifier, "simplifier", "", "simplifies the model terms by a `function` defined in smcview-simpl.maude")
+	flag.StringVar(&simplOpts.op, "simplifier", "", "simplifies the model terms by a Maude `function` (defined in the file given by -simplfile)")
+	flag.StringVar(&simplOpts.file, "simplfile", util.DefaultSimplifierFile, "Maude `file` where the simplifier function is defined")
+	flag.StringVar(&simplOpts.module, "simplmodule", "", "`module` where the simplifier function is reduced (the last one of -simplfile by default)")
+	flag.StringVar(&simplOpts.config, "simplconfig", "", "JSON `file` with a chain of simplifiers (of types maude, regex and elide) applied after -simplifier")
+	flag.DurationVar(&simplOpts.timeout, "simpltimeout", util.DefaultSimplifierTimeout, "maximum `time` to simplify a term with -simplifier before printing it unchanged (unlimited if zero)")
+	flag.IntVar(&simplOpts.maxLength, "elide", 0, "elides the middle of the terms longer than this `length` after the other simplifiers (disabled if zero)")
+	flag.StringVar(&modelOpts.file, "modelfile", "", "Maude `file` of the model, to print the truth table of the atomic propositions of the formula in the counterexample")
+	flag.StringVar(&modelOpts.module, "modelmodule", "", "`module` of the model where the dump was generated (the last one of -modelfile by default)")
+	flag.BoolVar(&modelOpts.rules, "rules", false, "print the rules and strategy definitions behind the transitions of the counterexample (requires -modelfile)")
 
 	// Usage information when -help is requested
 	flag.Usage = func() {
@@ -238,14 +557,14 @@ func main() {
 		return
 	}
 
-	// Inits an instance of the Maude interpreter, only when required
-	var maudec *maude.Client = nil
+	var simplifiers = simplifierSpecs(simplOpts)
 
-	if simplifier != "" || nargs == 0 {
-		if maudePath, maudeVersion := checkForMaude(maudePath); maudePath != "" {
-			maudec = maude.InitMaude(maudePath)
+	// Looks for the Maude interpreter, only when required
+	if util.NeedsMaude(simplifiers) || modelOpts.file != "" || nargs == 0 {
+		var maudeVersion string
 
-			if verbose {
+		if maudePath, maudeVersion = checkForMaude(maudePath); maudePath != "" {
+			if opts.verbose {
 				println("Maude:", maudePath)
 				println("Maude version:", maudeVersion)
 			}
@@ -256,8 +575,8 @@ func main() {
 	}
 
 	if nargs == 1 {
-		processDump(flag.Arg(0), graphMode, simplifier, maudec, graphPdf)
+		processDump(flag.Arg(0), graphMode, simplifiers, modelOpts, maudePath, graphPdf)
 	} else {
-		startServer(port, verbose, maudec, address, sourcedir, rootdir)
+		startServer(opts, simplifiers, maudePath)
 	}
 }
@@ -0,0 +1,86 @@
+package maude
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNotRunning is returned by client operations when the interpreter
+// has not been started or has already quit.
+var ErrNotRunning = errors.New("maude: the interpreter is not running")
+
+// ProcessExitedError is returned when the interpreter process exits
+// unexpectedly while executing an operation.
+type ProcessExitedError struct {
+	// Err is the error returned when waiting for the process, which
+	// describes its exit status (nil if it exited successfully)
+	Err error
+}
+
+func (e *ProcessExitedError) Error() string {
+	if e.Err == nil {
+		return "maude: the interpreter exited unexpectedly"
+	}
+
+	return "maude: the interpreter exited unexpectedly (" + e.Err.Error() + ")"
+}
+
+func (e *ProcessExitedError) Unwrap() error {
+	return e.Err
+}
+
+// TimeoutError is returned when the context of an operation is cancelled
+// or its deadline expires before the interpreter answers. The interpreter
+// is killed in that case, since its output cannot be trusted anymore.
+type TimeoutError struct {
+	// Err is the context error (context.Canceled or context.DeadlineExceeded)
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return "maude: the operation was interrupted (" + e.Err.Error() + ")"
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// UnexpectedOutputError is returned when the output of the interpreter
+// does not have the expected form.
+type UnexpectedOutputError struct {
+	// Command is the command sent to the interpreter
+	Command string
+	// Output is the output received from the interpreter
+	Output string
+}
+
+func (e *UnexpectedOutputError) Error() string {
+	return "maude: unexpected output for command '" + strings.TrimSpace(e.Command) + "'"
+}
+
+// ModuleError is returned when a module declared by the client to execute
+// some command is rejected by the interpreter.
+type ModuleError struct {
+	Module string
+	// Messages are the warnings and errors printed by Maude
+	Messages []Message
+}
+
+func (e *ModuleError) Error() string {
+	for _, message := range e.Messages {
+		if message.Level == "Warning" || message.Level == "Error" {
+			return "maude: module " + e.Module + " has errors (" + message.Text + ")"
+		}
+	}
+
+	return "maude: module " + e.Module + " has errors"
+}
+
+// IsCrash tells whether the error means that the interpreter is no longer
+// usable, because it has exited or it has been killed.
+func IsCrash(err error) bool {
+	var exited *ProcessExitedError
+	var timeout *TimeoutError
+
+	return errors.Is(err, ErrNotRunning) || errors.As(err, &exited) || errors.As(err, &timeout)
+}
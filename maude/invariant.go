@@ -0,0 +1,221 @@
+package maude
+
+import (
+	"context"
+	"github.com/ningit/smcview/maude/term"
+	"regexp"
+	"strconv"
+)
+
+// Constants and regular expressions for parsing Maude output
+var (
+	pathStateRegex = regexp.MustCompile("^state ([0-9]+), ([^:]+): (.*)$")
+	pathRuleRegex  = regexp.MustCompile("^===\\[ (.*) \\]===>$")
+	ruleLabelRegex = regexp.MustCompile("\\[(?:.* )?label ([^ \\]]+)")
+)
+
+// PathStep is a state of a path in the rewriting graph, with the rule or
+// strategy that leads to the next state.
+type PathStep struct {
+	// StateNr is the number of the state in the search graph (or -1)
+	StateNr int    `json:"stateNr"`
+	State   string `json:"state"`
+	Type    string `json:"type"`
+	// Rule is the rule or strategy applied in the step to the next state
+	// (empty for the last state)
+	Rule    string `json:"rule"`
+	// Label is the label of the rule (if any)
+	Label   string `json:"label"`
+}
+
+// InvariantResult is the result of checking an invariant.
+type InvariantResult struct {
+	Holds bool
+	// Witness is the path from the initial state to a state where the
+	// invariant does not hold (empty if it holds)
+	Witness []PathStep
+	// Complete tells whether all reachable states have been explored (it
+	// is false if the search has been bounded)
+	Complete bool
+	Stats    Stats
+}
+
+// ShowPath returns the path from the initial state to the given state in
+// the graph of the last search command.
+func (c *Client) ShowPath(ctx context.Context, stateNr int) ([]PathStep, error) {
+	var command = "show path " + strconv.Itoa(stateNr) + " .\n"
+
+	lines, err := c.exchange(ctx, command)
+	if err != nil {
+		return nil, err
+	}
+
+	var path = make([]PathStep, 0)
+
+	for _, line := range lines {
+		if match := pathStateRegex.FindStringSubmatch(line); match != nil {
+			number, _ := strconv.Atoi(match[1])
+			path = append(path, PathStep{StateNr: number, State: match[3], Type: match[2]})
+
+		} else if match := pathRuleRegex.FindStringSubmatch(line); match != nil && len(path) > 0 {
+			var step = &path[len(path)-1]
+			step.Rule = match[1]
+
+			if label := ruleLabelRegex.FindStringSubmatch(match[1]); label != nil {
+				step.Label = label[1]
+			}
+		}
+	}
+
+	if len(path) == 0 {
+		return nil, &UnexpectedOutputError{command, ""}
+	}
+
+	return path, nil
+}
+
+// Name of the module and the strategy declared by CheckInvariant to check
+// invariants under a strategy
+const (
+	invariantModule   = "%SMCVIEW-INVARIANT"
+	invariantStrategy = "%smcview-invariant"
+)
+
+// CheckInvariant checks whether the atomic proposition prop holds in every
+// state reachable from initial in the given module, using the satisfaction
+// operator _|=_. Without strategy, all states reachable by rewriting are
+// explored with search, up to the given depth if positive. With a strategy,
+// the formula [] prop is checked with the strategy-aware model checker, so
+// every state visited by the strategy is considered (the depth is ignored).
+func (c *Client) CheckInvariant(ctx context.Context, module, initial, prop, strategy string, depth int) (InvariantResult, error) {
+	c, release, err := c.acquire(ctx)
+	if err != nil {
+		return InvariantResult{}, err
+	}
+
+	defer release()
+
+	if strategy != "" {
+		return c.checkStrategyInvariant(ctx, module, initial, prop, strategy)
+	}
+
+	var result InvariantResult
+
+	sresult, err := c.Search(ctx, SearchQuery{
+		Module:       module,
+		Initial:      initial,
+		Arrow:        AnySteps,
+		Pattern:      "S:State",
+		Condition:    "S:State |= (" + prop + ") =/= true",
+		MaxSolutions: 1,
+		MaxDepth:     depth,
+	})
+
+	if err != nil {
+		return result, err
+	} else if !sresult.Ok {
+		return result, &UnexpectedOutputError{"search", ""}
+	}
+
+	result.Stats = sresult.Stats
+
+	if len(sresult.Solutions) == 0 {
+		result.Holds = true
+		result.Complete = depth <= 0
+		return result, nil
+	}
+
+	result.Witness, err = c.ShowPath(ctx, sresult.Solutions[0].State)
+
+	return result, err
+}
+
+// checkStrategyInvariant checks an invariant under a strategy by model
+// checking [] prop. The witness is the path of the counterexample up to the
+// first state where the proposition does not hold.
+func (c *Client) checkStrategyInvariant(ctx context.Context, module, initial, prop, strategy string) (InvariantResult, error) {
+	var result InvariantResult
+
+	// The model checker only admits named strategies, and the input module
+	// need not include the model checker
+	var imports = []term.Import{
+		{Mode: "protecting", Module: module},
+		{Mode: "including", Module: "STRATEGY-MODEL-CHECKER"},
+	}
+
+	var decls = "\tstrat " + invariantStrategy + " @ State .\n" +
+		"\tsd " + invariantStrategy + " := " + strategy + " .\n"
+
+	// A previous wrapper would remain with a different strategy if this
+	// one has errors
+	messages, ok, err := c.DeclareWrapper(ctx, invariantModule, imports, decls)
+
+	if err != nil {
+		return result, err
+	} else if !ok || HasErrors(messages) {
+		return result, &ModuleError{invariantModule, messages}
+	}
+
+	mcresult, err := c.ModelCheckIn(ctx, invariantModule, "modelCheck("+initial+", [] ("+prop+"), '"+
+		invariantStrategy+", nil)")
+	if err != nil {
+		return result, err
+	}
+
+	result.Stats = mcresult.Stats
+	result.Complete = true
+
+	if mcresult.Holds {
+		result.Holds = true
+		return result, nil
+	}
+
+	// The proposition fails somewhere in the counterexample, which is
+	// returned whole if that state cannot be identified
+	var lasso = append(mcresult.Path, mcresult.Cycle...)
+
+	if len(lasso) == 0 {
+		return result, &UnexpectedOutputError{"modelCheck", ""}
+	}
+
+	var end = len(lasso) - 1
+
+	for i, transition := range lasso {
+		eval, err := c.ReduceIn(ctx, invariantModule, "("+transition.State+") |= ("+prop+")")
+		if err != nil {
+			return result, err
+		}
+
+		if eval.Ok && eval.Term != "true" {
+			end = i
+			break
+		}
+	}
+
+	result.Witness = make([]PathStep, end+1)
+
+	for i, transition := range lasso[:end+1] {
+		result.Witness[i] = witnessStep(transition)
+	}
+
+	// The last state is where the witness stops
+	result.Witness[end].Rule = ""
+	result.Witness[end].Label = ""
+
+	return result, nil
+}
+
+// witnessStep converts a transition of a counterexample to a step of a path.
+func witnessStep(transition Transition) PathStep {
+	var step = PathStep{StateNr: -1, State: transition.State, Label: transition.Label}
+
+	switch transition.Kind {
+		case RuleTransition      : step.Rule = transition.Label
+		case UnlabeledTransition : step.Rule = "unlabeled"
+		case DeadlockTransition  : step.Rule = "deadlock"
+		case SolutionTransition  : step.Rule = "solution"
+		case OpaqueTransition    : step.Rule = "opaque " + transition.Label
+	}
+
+	return step
+}
@@ -4,12 +4,15 @@ package maude
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,220 +21,409 @@ var (
 	maudePrompt  = []byte("Maude> ")
 	promptLength = len(maudePrompt)
 
-	modRegex     = regexp.MustCompile("^(fmod|mod|smod|fth|th|sth) ([^ {]+)\n$")
+	modRegex     = regexp.MustCompile("^(fmod|mod|smod|fth|th|sth) ([^ {]+)$")
 )
 
-// Client is an access point for the Maude interpreter.
-type Client struct {
+// outputItem is either a line of the interpreter output (without its line
+// break) or the interpreter prompt.
+type outputItem struct {
+	line   string
+	prompt bool
+}
+
+// process is a running instance of the interpreter.
+type process struct {
+	command  *exec.Cmd
+	stdin    io.WriteCloser
+	stdout   chan outputItem
+	stderr   *stderrBuffer
+	// Closed when the interpreter process has exited
+	exited   chan struct{}
+	exitErr  error
+	// Closed when the process is killed, after which its output is
+	// discarded since no one will read it
+	killed   chan struct{}
+	killOnce sync.Once
+}
+
+// alive tells whether the process has not exited yet.
+func (p *process) alive() bool {
+	select {
+	case <-p.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// interpreter is the state of the interpreter shared by a client and the
+// views of it given to transactions.
+type interpreter struct {
 	maudePath string
-	command   *exec.Cmd
-	stdin     io.WriteCloser
-	stdout    *bufio.Reader
-	active    bool
+	// Environment variables for the interpreter (nil for the inherited ones)
+	env       []string
+	// Token for exclusive access to the interpreter (a channel of capacity
+	// one is used instead of a mutex to allow waiting with a context)
+	token     chan struct{}
+	// Protects proc, which Kill accesses while other commands are running
+	procMutex sync.Mutex
+	proc      *process
+	// Counter for the synchronization marks in the standard error
+	syncCount int
+	// Messages collected by the innermost running Capture (if any)
+	captured  *[]Message
+}
+
+// Client is an access point for the Maude interpreter. It can be used
+// concurrently from several goroutines, since every operation has exclusive
+// access to the interpreter while it runs. Sequences of operations that must
+// not be interleaved with others can be grouped with Transaction.
+type Client struct {
+	*interpreter
+	// Whether this is the view of the client inside a transaction, which
+	// already has exclusive access to the interpreter
+	inTransaction bool
 }
 
 // InitMaude creates a Maude client.
 func InitMaude(path string) *Client {
+	return &Client{interpreter: &interpreter{
+		maudePath: path,
+		token:     make(chan struct{}, 1),
+	}}
+}
+
+// acquire waits for exclusive access to the interpreter, unless the client
+// already has it. It returns the view of the client to be used while the
+// access is held and the function to release it. If the context is done
+// before access is granted, its error is returned.
+func (c *Client) acquire(ctx context.Context) (*Client, func(), error) {
+	if c.inTransaction {
+		return c, func() {}, nil
+	}
+
+	select {
+	case c.token <- struct{}{}:
+		return &Client{c.interpreter, true}, func() { <-c.token }, nil
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	}
+}
 
-	var client = Client{maudePath: path}
+// Transaction calls fn with exclusive access to the interpreter, so that
+// the operations done through the client it receives are not interleaved
+// with operations from other goroutines. That client must not be used after
+// fn returns. The error of fn is returned, or the context error if it ends
+// before the access is granted.
+func (c *Client) Transaction(ctx context.Context, fn func(tx *Client) error) error {
+	tx, release, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
 
-	client.initInternal()
+	defer release()
 
-	return &client
+	return fn(tx)
 }
 
-func consoleLogger(reader io.ReadCloser) {
-	buffered := bufio.NewReader(reader)
+// outputReader reads the standard output of the interpreter and sends it
+// line by line to the given channel, distinguishing the prompts. When the
+// output ends, it waits for the process and closes the channel.
+func (p *process) outputReader(stdout io.Reader) {
+	var reader = bufio.NewReader(stdout)
 
 	for {
-		str, err := buffered.ReadString('\n')
+		// The prompt is not followed by a line break
+		prompt, _ := reader.Peek(promptLength)
+
+		if bytes.Equal(prompt, maudePrompt) {
+			reader.Discard(promptLength)
+
+			if !p.emit(outputItem{prompt: true}) {
+				break
+			}
+			continue
+		}
+
+		line, err := reader.ReadString('\n')
+
+		if line != "" && !p.emit(outputItem{line: strings.TrimSuffix(line, "\n")}) {
+			break
+		}
 
-		// Probably program termination
 		if err != nil {
-			return
+			break
 		}
+	}
 
-		print("### ", str)
+	// The output of a killed process is read until its end, so that
+	// waiting for it does not block
+	io.Copy(ioutil.Discard, reader)
+
+	p.exitErr = p.command.Wait()
+	close(p.exited)
+	close(p.stdout)
+}
+
+// emit sends an item of the output to the channel, unless the process is
+// killed while waiting to send it. It returns whether it has been sent.
+func (p *process) emit(item outputItem) bool {
+	select {
+	case p.stdout <- item:
+		return true
+	case <-p.killed:
+		return false
 	}
 }
 
-func (client *Client) initInternal() {
+// kill kills the process and stops forwarding its output.
+func (p *process) kill() error {
+	p.killOnce.Do(func() { close(p.killed) })
+
+	return p.command.Process.Kill()
+}
+
+// Start runs a new fresh session of the Maude interpreter. It can be called
+// several times; if the client is still active, it will be quit.
+func (c *Client) Start(ctx context.Context) error {
+	c, release, err := c.acquire(ctx)
+	if err != nil {
+		return err
+	}
 
-	// Preserves the environment variables between consecutive executions
-	var oldEnviron []string = nil
+	defer release()
 
-	if client.command != nil {
-		oldEnviron = client.command.Env
+	if c.proc != nil && c.proc.alive() {
+		quitCtx, cancel := context.WithTimeout(ctx, time.Second)
+		c.Quit(quitCtx)
+		cancel()
 	}
 
-	client.command = exec.Command(client.maudePath, "-no-banner",
+	var command = exec.Command(c.maudePath, "-no-banner",
 		"-no-advise", "-no-wrap", "-no-ansi-color",
 		"-no-tecla", "-interactive")
 
-	if oldEnviron != nil {
-		client.command.Env = oldEnviron
-	}
+	command.Env = c.env
 
 	// Communication with Maude is based on pipes
-	client.stdin, _ = client.command.StdinPipe()
-	stdout, _ := client.command.StdoutPipe()
-	stderr, _ := client.command.StderrPipe()
+	stdin, err := command.StdinPipe()
+	if err != nil {
+		return err
+	}
 
-	client.stdout = bufio.NewReader(stdout)
+	stdout, err := command.StdoutPipe()
+	if err != nil {
+		return err
+	}
 
-	// The standard error is printed to the terminal by a goroutine
-	go consoleLogger(stderr)
+	stderr, err := command.StderrPipe()
+	if err != nil {
+		return err
+	}
 
-	client.active = false
-}
+	if err := command.Start(); err != nil {
+		return err
+	}
 
-// Load loads a source file within the Maude interpreter.
-func (c *Client) Load(source string) bool {
-	if !c.active {
-		return false
+	var proc = &process{
+		command: command,
+		stdin:   stdin,
+		stdout:  make(chan outputItem, 64),
+		stderr:  newStderrBuffer(),
+		exited:  make(chan struct{}),
+		killed:  make(chan struct{}),
 	}
 
-	c.stdin.Write([]byte("load " + source + " .\n"))
-	c.advanceUntilPrompt()
-	return true
-}
+	go proc.outputReader(stdout)
 
-// Quit politely quits from the Maude interpreter.
-func (c *Client) Quit() {
-	if c.active {
-		c.stdin.Write([]byte("quit .\n"))
-		c.command.Wait()
-		c.active = false
-	}
+	// The standard error is printed to the terminal by a goroutine
+	go consoleLogger(stderr, proc.stderr)
+
+	c.procMutex.Lock()
+	c.proc = proc
+	c.procMutex.Unlock()
+
+	// Waits for the first prompt
+	_, err = c.readUntilPrompt(ctx)
+
+	return err
 }
 
-// Kill terminates the interpreter process.
-func (c *Client) Kill() {
-	if c.active {
-		c.command.Process.Kill()
-		c.active = false
-	}
+// Running tells whether the interpreter is running.
+func (c *Client) Running() bool {
+	c.procMutex.Lock()
+	defer c.procMutex.Unlock()
+
+	return c.proc != nil && c.proc.alive()
 }
 
-// QuitTimeout tries to quit the interpreter politely, but if it does not quit
-// in the specified timeout (in milliseconds), the interpreter process is killed.
-func (c *Client) QuitTimeout(msec int) bool {
-	if !c.active {
-		return true
+// Quit politely quits from the Maude interpreter. If it does not quit
+// before the context is done, the interpreter process is killed.
+func (c *Client) Quit(ctx context.Context) error {
+	c, release, err := c.acquire(ctx)
+	if err != nil {
+		return err
 	}
 
-	// Channel to notify that the process has quit
-	chn := make(chan struct{}, 1)
+	defer release()
+
+	if c.proc == nil || !c.proc.alive() {
+		return nil
+	}
 
-	go func() {
-		c.Quit()
-		chn <- struct{}{}
-	}()
+	c.proc.stdin.Write([]byte("quit .\n"))
+	c.proc.stdin.Close()
 
 	select {
-	case <-chn:
-		return true
-	// If in msec time the process Quit has not succeeded,
-	// the process is killed
-	case <-time.After(time.Duration(msec) * time.Millisecond):
+	case <-c.proc.exited:
+		return nil
+	case <-ctx.Done():
 		c.Kill()
-		return false
+		return &TimeoutError{ctx.Err()}
 	}
 }
 
-// Start runs a new fresh session of the Maude interpreter. It can be called
-// several times; if the client is still active, it will be quit.
-func (c *Client) Start() {
-	if c.active {
-		c.QuitTimeout(1000)
-	}
+// Kill terminates the interpreter process. Unlike other operations, it
+// does not wait for exclusive access to the interpreter, so it can be used
+// to interrupt a running command, which will fail with a ProcessExitedError.
+func (c *Client) Kill() error {
+	c.procMutex.Lock()
+	var proc = c.proc
+	c.procMutex.Unlock()
 
-	c.initInternal()
-	c.command.Start()
-	c.active = true
-	c.advanceUntilPrompt()
-}
+	if proc == nil || !proc.alive() {
+		return nil
+	}
 
-// CurrentModuleName gets the name of the current module for the interpreter.
-func (c *Client) CurrentModuleName() string {
-	if !c.active {
-		return ""
+	if err := proc.kill(); err != nil {
+		return err
 	}
 
-	c.stdin.Write([]byte("show module .\n"))
-	defer c.advanceUntilPrompt()
+	<-proc.exited
+
+	return nil
+}
 
-	if !c.promptReached() {
-		str, _ := c.stdout.ReadString('\n')
+// readUntilPrompt reads the interpreter output until the next prompt and
+// returns its lines. If the context is done before, the interpreter is
+// killed. Exclusive access to the interpreter is required.
+func (c *Client) readUntilPrompt(ctx context.Context) ([]string, error) {
+	var lines = make([]string, 0)
 
-		if match := moddeclRegex.FindStringSubmatch(str); match != nil {
-			return match[2]
+	for {
+		select {
+		case item, ok := <-c.proc.stdout:
+			if !ok {
+				// The interpreter has exited
+				return lines, &ProcessExitedError{c.proc.exitErr}
+			}
+
+			if item.prompt {
+				return lines, nil
+			}
+
+			lines = append(lines, item.line)
+
+		case <-ctx.Done():
+			c.Kill()
+			return lines, &TimeoutError{ctx.Err()}
 		}
 	}
-
-	return ""
 }
 
-// Select selects a module in the Maude interpreter.
-func (c *Client) Select(module string) {
-	if c.active {
-		c.stdin.Write([]byte("select " + module + " .\n"))
-		c.advanceUntilPrompt()
+// send sends a command to the interpreter and returns the lines of its
+// output until the next prompt. Exclusive access to the interpreter is
+// required.
+func (c *Client) send(ctx context.Context, command string) ([]string, error) {
+	if c.proc == nil || !c.proc.alive() {
+		return nil, ErrNotRunning
 	}
-}
 
-// SetMixfix enables or disables printing in mixfix syntax.
-func (c *Client) SetMixfix(value bool) {
-	if !c.active {
-		return
+	// Nothing is sent if the context is already done
+	if err := ctx.Err(); err != nil {
+		return nil, &TimeoutError{err}
 	}
 
-	switch value {
-	case true:
-		c.stdin.Write([]byte("set print mixfix on .\n"))
-	case false:
-		c.stdin.Write([]byte("set print mixfix off .\n"))
+	if _, err := c.proc.stdin.Write([]byte(command)); err != nil {
+		// The interpreter has probably exited, but we wait to be sure
+		select {
+		case <-c.proc.exited:
+			return nil, &ProcessExitedError{c.proc.exitErr}
+		case <-time.After(100 * time.Millisecond):
+			return nil, err
+		}
 	}
 
-	c.advanceUntilPrompt()
+	return c.readUntilPrompt(ctx)
 }
 
-// RawInput intoduces raw input (followed by a line break) to the Maude
-// interpreter and returns its output.
-func (c *Client) RawInput(input string) string {
-	if !c.active {
-		return "inactive"
+// exchange sends a command to the interpreter with exclusive access and
+// returns the lines of its output.
+func (c *Client) exchange(ctx context.Context, command string) ([]string, error) {
+	c, release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	var output strings.Builder
+	defer release()
+
+	return c.send(ctx, command)
+}
 
-	c.stdin.Write([]byte(input + "\n"))
+// Load loads a source file within the Maude interpreter.
+func (c *Client) Load(ctx context.Context, source string) error {
+	_, err := c.exchange(ctx, "load "+source+" .\n")
+	return err
+}
 
-	for !c.promptReached() {
-		str, _ := c.stdout.ReadString('\n')
-		output.WriteString(str)
+// CurrentModuleName gets the name of the current module for the interpreter.
+func (c *Client) CurrentModuleName(ctx context.Context) (string, error) {
+	const command = "show module .\n"
+
+	lines, err := c.exchange(ctx, command)
+	if err != nil {
+		return "", err
 	}
 
-	c.advanceUntilPrompt()
+	if len(lines) > 0 {
+		if match := moddeclRegex.FindStringSubmatch(lines[0]); match != nil {
+			return match[2], nil
+		}
+	}
 
-	return output.String()
+	return "", &UnexpectedOutputError{command, strings.Join(lines, "\n")}
 }
 
-func (c *Client) promptReached() bool {
-	prompt, _ := c.stdout.Peek(promptLength)
+// Select selects a module in the Maude interpreter.
+func (c *Client) Select(ctx context.Context, module string) error {
+	_, err := c.exchange(ctx, "select "+module+" .\n")
+	return err
+}
 
-	return bytes.Equal(maudePrompt, prompt)
+// SetMixfix enables or disables printing in mixfix syntax.
+func (c *Client) SetMixfix(ctx context.Context, value bool) error {
+	var err error
+
+	switch value {
+	case true:
+		_, err = c.exchange(ctx, "set print mixfix on .\n")
+	case false:
+		_, err = c.exchange(ctx, "set print mixfix off .\n")
+	}
+
+	return err
 }
 
-func (c *Client) advanceUntilPrompt() {
-	prompt, _ := c.stdout.Peek(promptLength)
+// RawInput intoduces raw input (followed by a line break) to the Maude
+// interpreter and returns its output.
+func (c *Client) RawInput(ctx context.Context, input string) (string, error) {
+	lines, err := c.exchange(ctx, input+"\n")
 
-	for !bytes.Equal(maudePrompt, prompt) {
-		c.stdout.ReadString('\n')
-		prompt, _ = c.stdout.Peek(promptLength)
+	if err != nil {
+		return "", err
 	}
 
-	c.stdout.Discard(len(maudePrompt))
+	return strings.Join(lines, "\n"), nil
 }
 
 // LocateMaude looks for the Maude executable in the host system, returning
@@ -0,0 +1,117 @@
+package maude
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakeInterpreter writes a shell script that imitates the interpreter,
+// printing the prompt and then the output of the given command for the
+// first command it receives.
+func fakeInterpreter(t *testing.T, command string) (string, func()) {
+	return fakeScript(t, "printf 'Maude> '\nread line\nexec "+command+"\n")
+}
+
+// fakeScript writes a shell script with the given content to be used as
+// the interpreter, and returns its path and a function to remove it.
+func fakeScript(t *testing.T, content string) (string, func()) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake interpreter is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "smcview-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var path = filepath.Join(dir, "maude")
+
+	if err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+content), 0755); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestKillWithUnreadOutput(t *testing.T) {
+	path, cleanup := fakeInterpreter(t, "yes 'Solution 1'")
+	defer cleanup()
+
+	var client = InitMaude(path)
+
+	if err := client.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The command never ends and its output exceeds the buffer of the
+	// client, so it is killed when the timeout expires
+	var done = make(chan error, 1)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		_, err := client.exchange(ctx, "rew loop .\n")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		var timeout *TimeoutError
+
+		if !errors.As(err, &timeout) {
+			t.Errorf("the command fails with %v, expected a timeout", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the client is blocked after killing the interpreter")
+	}
+
+	if client.Running() {
+		t.Error("the interpreter is still running after being killed")
+	}
+
+	// Killing it again does nothing
+	if err := client.Kill(); err != nil {
+		t.Errorf("killing a dead interpreter fails with %v", err)
+	}
+}
+
+func TestKillWhileIdle(t *testing.T) {
+	path, cleanup := fakeInterpreter(t, "yes 'Solution 1'")
+	defer cleanup()
+
+	var client = InitMaude(path)
+
+	if err := client.Start(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The output of the command is not read by anyone
+	if _, err := client.proc.stdin.Write([]byte("rew loop .\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	var done = make(chan error, 1)
+
+	go func() {
+		done <- client.Kill()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Kill fails with %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Kill is blocked by the unread output")
+	}
+}
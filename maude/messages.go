@@ -0,0 +1,233 @@
+package maude
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Constants and regular expressions for parsing Maude messages
+var (
+	messageRegex  = regexp.MustCompile("^(Warning|Advisory|Error): (?:\"([^\"]*)\"|<([^>]*)>), line ([0-9]+)(?: \\(((?:[^()]|\\([^()]*\\))*)\\))?: (.*)$")
+	levelRegex    = regexp.MustCompile("^(Warning|Advisory|Error): (.*)$")
+)
+
+const (
+	// Maximum number of lines kept from the standard error (older lines
+	// are discarded when no one collects them)
+	maxStderrLines = 1000
+	// Maximum time to wait for the synchronization mark in the standard error
+	syncTimeout = 2 * time.Second
+)
+
+// Message is a diagnostic message (warning, advisory or error) printed by
+// Maude to its standard error, with its source location when available.
+type Message struct {
+	Level string `json:"level"`
+	// File is the source file as printed by Maude (it may be a relative path,
+	// or empty if the message does not refer to a file)
+	File string `json:"file"`
+	// Line is the line number within File (or zero if unknown)
+	Line int `json:"line"`
+	// Context is the module or statement where the message originates
+	Context string `json:"context"`
+	Text    string `json:"text"`
+}
+
+// stderrBuffer collects the lines written by Maude to its standard error.
+type stderrBuffer struct {
+	mutex sync.Mutex
+	cond  *sync.Cond
+	lines []string
+}
+
+func newStderrBuffer() *stderrBuffer {
+	var buffer = &stderrBuffer{}
+	buffer.cond = sync.NewCond(&buffer.mutex)
+	return buffer
+}
+
+// consoleLogger prints the standard error of Maude to the terminal and
+// keeps a copy of its lines in the buffer.
+func consoleLogger(reader io.ReadCloser, buffer *stderrBuffer) {
+	buffered := bufio.NewReader(reader)
+
+	for {
+		str, err := buffered.ReadString('\n')
+
+		// Probably program termination
+		if err != nil {
+			buffer.cond.Broadcast()
+			return
+		}
+
+		print("### ", str)
+
+		buffer.mutex.Lock()
+		buffer.lines = append(buffer.lines, strings.TrimSuffix(str, "\n"))
+		if len(buffer.lines) > maxStderrLines {
+			buffer.lines = buffer.lines[1:]
+		}
+		buffer.mutex.Unlock()
+		buffer.cond.Broadcast()
+	}
+}
+
+// reset discards all lines in the buffer.
+func (b *stderrBuffer) reset() {
+	b.mutex.Lock()
+	b.lines = nil
+	b.mutex.Unlock()
+}
+
+// waitFor waits until a line containing the given token is written and
+// returns all lines before it. If the token does not arrive in time, all
+// lines received so far are returned.
+func (b *stderrBuffer) waitFor(token string) []string {
+	var deadline = time.Now().Add(syncTimeout)
+	var timer = time.AfterFunc(syncTimeout, b.cond.Broadcast)
+	defer timer.Stop()
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	for {
+		for i, line := range b.lines {
+			if strings.Contains(line, token) {
+				var lines = b.lines[:i]
+				b.lines = b.lines[i+1:]
+				return lines
+			}
+		}
+
+		if time.Now().After(deadline) {
+			var lines = b.lines
+			b.lines = nil
+			return lines
+		}
+
+		b.cond.Wait()
+	}
+}
+
+// parseMessages parses the lines written by Maude to its standard error.
+// Lines that do not start a new message are appended to the previous one.
+func parseMessages(lines []string) []Message {
+	var messages = make([]Message, 0, len(lines))
+
+	for _, line := range lines {
+		if match := messageRegex.FindStringSubmatch(line); match != nil {
+			lineNr, _ := strconv.Atoi(match[4])
+			messages = append(messages, Message{
+				Level:   match[1],
+				File:    match[2],
+				Line:    lineNr,
+				Context: match[5],
+				Text:    match[6],
+			})
+		} else if match := levelRegex.FindStringSubmatch(line); match != nil {
+			messages = append(messages, Message{Level: match[1], Text: match[2]})
+		} else if len(messages) > 0 {
+			messages[len(messages)-1].Text += "\n" + line
+		} else if line != "" {
+			messages = append(messages, Message{Text: line})
+		}
+	}
+
+	return messages
+}
+
+// syncStderr writes a harmless command that produces a known warning
+// after the previous ones, so that all messages printed by them can be
+// collected from the standard error.
+func (c *Client) syncStderr(ctx context.Context) ([]Message, error) {
+	c.syncCount++
+
+	var token = "%SMCVIEW-SYNC-" + strconv.Itoa(c.syncCount)
+
+	if _, err := c.send(ctx, "select "+token+" .\n"); err != nil {
+		return nil, err
+	}
+
+	return parseMessages(c.proc.stderr.waitFor(token)), nil
+}
+
+// Capture calls fn with exclusive access to the interpreter, like
+// Transaction, and returns the messages printed by Maude to its standard
+// error while the commands in fn are executed. Captures can be nested, and
+// the messages of the inner ones are also returned by the outer ones.
+func (c *Client) Capture(ctx context.Context, fn func(tx *Client) error) ([]Message, error) {
+	c, release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	if c.proc == nil || !c.proc.alive() {
+		return nil, ErrNotRunning
+	}
+
+	// The messages of previous commands are given to the enclosing capture
+	// or discarded
+	var outer = c.captured
+
+	if outer == nil {
+		c.proc.stderr.reset()
+	} else {
+		pending, err := c.syncStderr(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		*outer = append(*outer, pending...)
+	}
+
+	var messages = make([]Message, 0)
+
+	c.captured = &messages
+	err = fn(c)
+	c.captured = outer
+
+	// The remaining messages cannot be collected if the interpreter is dead
+	if !IsCrash(err) {
+		pending, serr := c.syncStderr(ctx)
+
+		if err == nil {
+			err = serr
+		}
+
+		messages = append(messages, pending...)
+	}
+
+	if outer != nil {
+		*outer = append(*outer, messages...)
+	}
+
+	return messages, err
+}
+
+// LoadChecked loads a source file like Load, but also returns the messages
+// printed by Maude while loading it.
+func (c *Client) LoadChecked(ctx context.Context, source string) ([]Message, error) {
+	return c.Capture(ctx, func(tx *Client) error {
+		return tx.Load(ctx, source)
+	})
+}
+
+// HasErrors tells whether any of the messages is a warning or an error
+// (advisories are only informative).
+func HasErrors(messages []Message) bool {
+	for _, message := range messages {
+		if message.Level == "Warning" || message.Level == "Error" {
+			return true
+		}
+	}
+
+	return false
+}
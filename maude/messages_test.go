@@ -0,0 +1,71 @@
+package maude
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMessages(t *testing.T) {
+	var tests = []struct {
+		name     string
+		stderr   string
+		expected []Message
+	}{
+		{"source file", `Warning: "/home/user/counter.maude", line 12 (mod COUNTER): this rule has a variable X in its rhs that is not bound.
+Advisory: "counter.maude", line 3 (fmod NAT-LIST): redefining module NAT-LIST.`,
+			[]Message{
+				{"Warning", "/home/user/counter.maude", 12, "mod COUNTER", "this rule has a variable X in its rhs that is not bound."},
+				{"Advisory", "counter.maude", 3, "fmod NAT-LIST", "redefining module NAT-LIST."},
+			}},
+		// Continuation lines are appended to the previous message, and locations
+		// like <standard input> are not files
+		{"standard input", `Warning: <standard input>, line 1: didn't expect token foo:
+red in NAT : 1 + foo <---*HERE*
+Warning: <standard input>, line 1: no parse for term.`,
+			[]Message{
+				{"Warning", "", 1, "", "didn't expect token foo:\nred in NAT : 1 + foo <---*HERE*"},
+				{"Warning", "", 1, "", "no parse for term."},
+			}},
+		{"without location", `Error: unable to open file "missing.maude".`,
+			[]Message{
+				{"Error", "", 0, "", "unable to open file \"missing.maude\"."},
+			}},
+		// Lines before the first message are kept as messages without level
+		{"unknown", `
+Maude internal error: unexpected state.
+Warning: "a b.maude", line 7 (smod WALK (in view Walk)): strategy walk is not defined.`,
+			[]Message{
+				{"", "", 0, "", "Maude internal error: unexpected state."},
+				{"Warning", "a b.maude", 7, "smod WALK (in view Walk)", "strategy walk is not defined."},
+			}},
+		{"empty", ``, []Message{}},
+	}
+
+	for _, test := range tests {
+		var messages = parseMessages(strings.Split(test.stderr, "\n"))
+
+		if !reflect.DeepEqual(messages, test.expected) {
+			t.Errorf("%s: parseMessages gives %+v, expected %+v", test.name, messages, test.expected)
+		}
+	}
+}
+
+func TestHasErrors(t *testing.T) {
+	var tests = []struct {
+		messages []Message
+		expected bool
+	}{
+		{[]Message{}, false},
+		{[]Message{{Level: "Advisory", Text: "redefining module M."}}, false},
+		{[]Message{{Level: "Advisory"}, {Level: "Warning"}}, true},
+		{[]Message{{Level: "Error"}}, true},
+		{[]Message{{Text: "Maude internal error"}}, false},
+	}
+
+	for _, test := range tests {
+		if result := HasErrors(test.messages); result != test.expected {
+			t.Errorf("HasErrors(%+v) = %v, expected %v", test.messages, result, test.expected)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package maude
+
+import (
+	"context"
+	"github.com/ningit/smcview/maude/term"
+	"strings"
+)
+
+// metaLevel reduces some terms in the META-LEVEL module and parses their
+// results. The terms are built by a function from the Qid of the current
+// module, which is selected again afterwards.
+func (c *Client) metaLevel(ctx context.Context, build func(module string) []string) ([]*term.Node, error) {
+	c, release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	// The function should not change the current module
+	module, err := c.CurrentModuleName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.Select(ctx, module)
+
+	// Metarepresentations are printed in prefix form to be easily parsed
+	if err := c.SetMixfix(ctx, false); err != nil {
+		return nil, err
+	}
+
+	defer c.SetMixfix(ctx, true)
+
+	var inputs = build(term.Qid(module))
+	var results = make([]*term.Node, len(inputs))
+
+	for i, input := range inputs {
+		result, err := c.ReduceIn(ctx, "META-LEVEL", input)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Ok {
+			results[i], err = term.ParsePrefix(result.Term)
+		}
+
+		if !result.Ok || err != nil {
+			return nil, &UnexpectedOutputError{"red in META-LEVEL : " + input + " .", result.Term}
+		}
+	}
+
+	return results, nil
+}
+
+// DeclareWrapper declares a strategy module with the given name that imports
+// the given modules (in this order) and adds some declarations to them, to
+// execute commands in their combination. The current module is selected
+// again afterwards. It returns the messages printed by Maude while processing
+// the wrapper and whether it has been declared with the given imports, since
+// a previous wrapper with the same name may remain if it has errors.
+func (c *Client) DeclareWrapper(ctx context.Context, name string, imports []term.Import, decls string) ([]Message, bool, error) {
+	c, release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer release()
+
+	current, err := c.CurrentModuleName(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	defer c.Select(ctx, current)
+
+	var builder strings.Builder
+
+	builder.WriteString("smod " + name + " is\n")
+
+	for _, imp := range imports {
+		builder.WriteString("\t" + imp.Mode + " " + imp.Module + " .\n")
+	}
+
+	builder.WriteString(decls)
+	builder.WriteString("endsm")
+
+	// Errors in the declarations (like unbound variables in strategy
+	// definitions) are reported when the module is selected
+	messages, err := c.Capture(ctx, func(tx *Client) error {
+		if _, err := tx.RawInput(ctx, builder.String()); err != nil {
+			return err
+		}
+
+		return tx.Select(ctx, name)
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	minfo, err := c.UpModule(ctx, name, false)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if minfo == nil || len(minfo.Imports) != len(imports) {
+		return messages, false, nil
+	}
+
+	for i, imp := range imports {
+		if minfo.Imports[i].Module != imp.Module {
+			return messages, false, nil
+		}
+	}
+
+	return messages, true, nil
+}
+
+// Name of the module where terms are metarepresented by UpTerms
+const upTermModule = "%SMCVIEW-UPTERM"
+
+// UpTerms obtains the metarepresentation of some terms of the given module,
+// reduced in that module, to know their structure. The result is nil for
+// the terms that cannot be parsed or metarepresented (also when the module
+// cannot be combined with META-LEVEL because of name clashes).
+func (c *Client) UpTerms(ctx context.Context, module string, terms []string) ([]*term.Term, error) {
+	var results = make([]*term.Term, len(terms))
+
+	_, ok, err := c.DeclareWrapper(ctx, upTermModule, metaWrapperImports(module), "")
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return results, nil
+	}
+
+	for i, input := range terms {
+		result, err := c.ReduceIn(ctx, upTermModule, "upTerm(("+input+"))")
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Ok {
+			results[i], _ = term.Parse(result.Term)
+		}
+	}
+
+	return results, nil
+}
+
+// metaWrapperImports are the imports of a wrapper that combines a module
+// with META-LEVEL.
+func metaWrapperImports(module string) []term.Import {
+	return []term.Import{{Mode: "protecting", Module: "META-LEVEL"}, {Mode: "including", Module: module}}
+}
@@ -1,6 +1,7 @@
 package maude
 
 import (
+	"context"
 	"os"
 	"strings"
 )
@@ -9,40 +10,231 @@ import (
 // strategy-aware model checker. An empty string disables such extended
 // output. For the change to take effect, Start must be called afterwards.
 func (c *Client) SetSmcOutput(path string) {
-	if c.command.Env == nil {
-		c.command.Env = os.Environ()
+	c, release, _ := c.acquire(context.Background())
+	defer release()
+
+	if c.env == nil {
+		c.env = os.Environ()
 	}
 
-	for i := len(c.command.Env) - 1; i >= 0; i-- {
-		if strings.HasPrefix(c.command.Env[i], "MAUDE_SMC_OUTPUT=") {
-			c.command.Env[i] = "MAUDE_SMC_OUTPUT=" + path
+	for i := len(c.env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(c.env[i], "MAUDE_SMC_OUTPUT=") {
+			c.env[i] = "MAUDE_SMC_OUTPUT=" + path
 			return
 		}
 	}
 
 	// Only if not found within the environment
-	c.command.Env = append(c.command.Env,
-		"MAUDE_SMC_OUTPUT="+path,
-	)
+	c.env = append(c.env, "MAUDE_SMC_OUTPUT="+path)
+}
+
+// SmcOutput returns the extended output path for the strategy-aware model
+// checker, or the empty string if it is not set.
+func (c *Client) SmcOutput() string {
+	c, release, _ := c.acquire(context.Background())
+	defer release()
+
+	for i := len(c.env) - 1; i >= 0; i-- {
+		if strings.HasPrefix(c.env[i], "MAUDE_SMC_OUTPUT=") {
+			return c.env[i][len("MAUDE_SMC_OUTPUT="):]
+		}
+	}
+
+	return ""
 }
 
 // SmcAvailable checks if the strategy model checker is available
 // in the current module.
-func (c *Client) SmcAvailable() bool {
-	if !c.active {
-		return false
+func (c *Client) SmcAvailable(ctx context.Context) (bool, error) {
+	lines, err := c.exchange(ctx, "show op .\n")
+	if err != nil {
+		return false, err
 	}
 
-	c.stdin.Write([]byte("show op .\n"))
-	defer c.advanceUntilPrompt()
+	for _, line := range lines {
+		if line == "    id-hook StrategyModelCheckerSymbol" {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// TransitionKind classifies the transitions of a counterexample.
+type TransitionKind int
+
+const (
+	// RuleTransition is the application of a labelled rule
+	RuleTransition TransitionKind = iota
+	// UnlabeledTransition is the application of a rule without label
+	UnlabeledTransition
+	// DeadlockTransition is the self-loop added to deadlock states
+	DeadlockTransition
+	// SolutionTransition is the self-loop added to solutions of the strategy
+	SolutionTransition
+	// OpaqueTransition is the execution of an opaque strategy
+	OpaqueTransition
+)
+
+// Transition is a step of a counterexample, with the state where it
+// starts and the rule or strategy that is applied.
+type Transition struct {
+	State string         `json:"state"`
+	Kind  TransitionKind `json:"kind"`
+	// Label is the rule label or the name of the opaque strategy
+	Label string         `json:"label"`
+}
+
+// ModelCheckResult is the parsed result of the model checker, which is
+// either that the property holds or a counterexample made of a path and
+// a cycle.
+type ModelCheckResult struct {
+	Holds bool
+	Path  []Transition
+	Cycle []Transition
+	Stats Stats
+}
+
+// ModelCheckIn reduces the given model checker call in a module and
+// parses its result.
+func (c *Client) ModelCheckIn(ctx context.Context, module, term string) (ModelCheckResult, error) {
+	result, err := c.ReduceIn(ctx, module, term)
+	if err != nil {
+		return ModelCheckResult{}, err
+	}
+
+	return ParseModelCheckResult(result)
+}
+
+// ParseModelCheckResult parses the result of the reduction of a model
+// checker call, which is either true or a counterexample term.
+func ParseModelCheckResult(result ReduceResult) (ModelCheckResult, error) {
+	var mcresult = ModelCheckResult{Stats: result.Stats}
+	var term = strings.TrimSpace(result.Term)
+	var unexpected = &UnexpectedOutputError{"modelCheck", term}
+
+	if !result.Ok {
+		return mcresult, unexpected
+	}
+
+	if term == "true" {
+		mcresult.Holds = true
+		return mcresult, nil
+	}
+
+	const prefix = "counterexample("
+
+	if !strings.HasPrefix(term, prefix) || !strings.HasSuffix(term, ")") {
+		return mcresult, unexpected
+	}
+
+	var args = term[len(prefix) : len(term)-1]
+	var commas = topLevel(args, ',')
+
+	if len(commas) != 1 {
+		return mcresult, unexpected
+	}
+
+	var ok bool
+
+	if mcresult.Path, ok = parseTransitions(args[:commas[0]]); !ok {
+		return mcresult, unexpected
+	}
+
+	if mcresult.Cycle, ok = parseTransitions(args[commas[0]+1:]); !ok {
+		return mcresult, unexpected
+	}
+
+	return mcresult, nil
+}
+
+// parseTransitions parses a list of transitions of the form {state, label}
+// juxtaposed, or nil.
+func parseTransitions(list string) ([]Transition, bool) {
+	var transitions = make([]Transition, 0)
+
+	list = strings.TrimSpace(list)
+
+	if list == "nil" {
+		return transitions, true
+	}
+
+	var opens = topLevel(list, '{')
+	var closes = topLevel(list, '}')
+
+	if len(opens) == 0 || len(opens) != len(closes) {
+		return nil, false
+	}
+
+	for i, open := range opens {
+		var inner = list[open+1 : closes[i]]
+		var commas = topLevel(inner, ',')
+
+		// The state may contain commas, but the label does not
+		if len(commas) == 0 {
+			return nil, false
+		}
+
+		var last = commas[len(commas)-1]
+		var transition = Transition{State: strings.TrimSpace(inner[:last])}
+		var label = strings.TrimSpace(inner[last+1:])
+
+		switch {
+		case label == "unlabeled":
+			transition.Kind = UnlabeledTransition
+		case label == "deadlock":
+			transition.Kind = DeadlockTransition
+		case label == "solution":
+			transition.Kind = SolutionTransition
+		case strings.HasPrefix(label, "opaque(") && strings.HasSuffix(label, ")"):
+			transition.Kind = OpaqueTransition
+			transition.Label = strings.TrimPrefix(label[7:len(label)-1], "'")
+		default:
+			transition.Kind = RuleTransition
+			transition.Label = strings.TrimPrefix(label, "'")
+		}
+
+		transitions = append(transitions, transition)
+	}
+
+	return transitions, true
+}
 
-	for !c.promptReached() {
-		str, _ := c.stdout.ReadString('\n')
+// topLevel returns the positions of the given character in a term where
+// it occurs outside parentheses, brackets, braces and string literals.
+// Opening symbols are found at the level where they are opened, and closing
+// symbols at the level they return to.
+func topLevel(term string, char byte) []int {
+	var positions = make([]int, 0)
+	var depth = 0
 
-		if str == "    id-hook StrategyModelCheckerSymbol\n" {
-			return true
+	for i := 0; i < len(term); i++ {
+		switch term[i] {
+		case '`':
+			// Special characters are escaped by a backquote
+			i++
+		case '"':
+			for i++; i < len(term) && term[i] != '"'; i++ {
+				if term[i] == '\\' {
+					i++
+				}
+			}
+		case '(', '[', '{':
+			if depth == 0 && term[i] == char {
+				positions = append(positions, i)
+			}
+			depth++
+		case ')', ']', '}':
+			depth--
+			if depth == 0 && term[i] == char {
+				positions = append(positions, i)
+			}
+		default:
+			if depth == 0 && term[i] == char {
+				positions = append(positions, i)
+			}
 		}
 	}
 
-	return false
+	return positions
 }
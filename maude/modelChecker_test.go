@@ -0,0 +1,112 @@
+package maude
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseStats(t *testing.T) {
+	var tests = []struct {
+		line  string
+		ok    bool
+		stats Stats
+	}{
+		{"rewrites: 1234 in 5ms cpu (6ms real) (246800 rewrites/second)", true,
+			Stats{true, 0, 1234, 5 * time.Millisecond, 6 * time.Millisecond}},
+		{"rewrites: 0 in 0ms cpu (0ms real) (~ rewrites/second)", true,
+			Stats{true, 0, 0, 0, 0}},
+		{"states: 3  rewrites: 17 in 1ms cpu (2ms real) (17000 rewrites/second)", true,
+			Stats{true, 3, 17, time.Millisecond, 2 * time.Millisecond}},
+		// Maude may print negative times when the clock is adjusted
+		{"rewrites: 8 in -1ms cpu (0ms real) (~ rewrites/second)", true,
+			Stats{true, 0, 8, -time.Millisecond, 0}},
+		{"rewrites: 9223372036854775807 in 0ms cpu (0ms real) (~ rewrites/second)", true,
+			Stats{true, 0, 9223372036854775807, 0, 0}},
+		{"result Bool: true", false, Stats{}},
+		{"Solution 1 (state 2)", false, Stats{}},
+		{"states: 3 rewrites: 17 in 1ms cpu (2ms real)", false, Stats{}},
+		{"  rewrites: 1 in 0ms cpu (0ms real)", false, Stats{}},
+		{"", false, Stats{}},
+	}
+
+	for _, test := range tests {
+		var stats Stats
+
+		if ok := parseStats(test.line, &stats); ok != test.ok {
+			t.Errorf("parseStats(%q) = %v, expected %v", test.line, ok, test.ok)
+		} else if stats != test.stats {
+			t.Errorf("parseStats(%q) gives %+v, expected %+v", test.line, stats, test.stats)
+		}
+	}
+}
+
+func TestParseModelCheckResult(t *testing.T) {
+	var stats = Stats{true, 0, 42, time.Millisecond, time.Millisecond}
+
+	var tests = []struct {
+		result   ReduceResult
+		expected ModelCheckResult
+		ok       bool
+	}{
+		{ReduceResult{true, "true", "Bool", stats}, ModelCheckResult{Holds: true, Stats: stats}, true},
+		{ReduceResult{true, "counterexample({0,'inc} {1,'inc}, {2,deadlock})", "ModelCheckResult", stats},
+			ModelCheckResult{
+				Path:  []Transition{{"0", RuleTransition, "inc"}, {"1", RuleTransition, "inc"}},
+				Cycle: []Transition{{"2", DeadlockTransition, ""}},
+				Stats: stats,
+			}, true},
+		// States with commas, parentheses and braces, and multiline terms
+		{ReduceResult{true, "counterexample({< a, b > {c, d},unlabeled}\n{f(x, \"}\"), 'step}, {g(y),\n'back} {h(z, w), unlabeled})", "ModelCheckResult", stats},
+			ModelCheckResult{
+				Path: []Transition{
+					{"< a, b > {c, d}", UnlabeledTransition, ""},
+					{"f(x, \"}\")", RuleTransition, "step"},
+				},
+				Cycle: []Transition{
+					{"g(y)", RuleTransition, "back"},
+					{"h(z, w)", UnlabeledTransition, ""},
+				},
+				Stats: stats,
+			}, true},
+		// Strategy-controlled model checking
+		{ReduceResult{true, "counterexample(nil, {s(0), opaque('walk)} {s(s(0)), solution})", "ModelCheckResult", stats},
+			ModelCheckResult{
+				Path:  []Transition{},
+				Cycle: []Transition{{"s(0)", OpaqueTransition, "walk"}, {"s(s(0))", SolutionTransition, ""}},
+				Stats: stats,
+			}, true},
+		// Escaped special characters in the state
+		{ReduceResult{true, "counterexample({`{ 1 `}, 'a}, {`( 2 `), 'b})", "ModelCheckResult", stats},
+			ModelCheckResult{
+				Path:  []Transition{{"`{ 1 `}", RuleTransition, "a"}},
+				Cycle: []Transition{{"`( 2 `)", RuleTransition, "b"}},
+				Stats: stats,
+			}, true},
+		{ReduceResult{false, "", "", Stats{}}, ModelCheckResult{}, false},
+		{ReduceResult{true, "false", "Bool", stats}, ModelCheckResult{}, false},
+		{ReduceResult{true, "modelCheck(0, [] p)", "[ModelCheckResult]", stats}, ModelCheckResult{}, false},
+		{ReduceResult{true, "counterexample({0,'inc})", "ModelCheckResult", stats}, ModelCheckResult{}, false},
+		{ReduceResult{true, "counterexample({0,'inc}, {1})", "ModelCheckResult", stats}, ModelCheckResult{}, false},
+		{ReduceResult{true, "counterexample({0,'inc}, {1,'inc}, nil)", "ModelCheckResult", stats}, ModelCheckResult{}, false},
+		{ReduceResult{true, "counterexample({0,'inc} {1,'inc, nil)", "ModelCheckResult", stats}, ModelCheckResult{}, false},
+		{ReduceResult{true, "counterexample(x, nil)", "ModelCheckResult", stats}, ModelCheckResult{}, false},
+	}
+
+	for _, test := range tests {
+		result, err := ParseModelCheckResult(test.result)
+
+		if !test.ok {
+			if _, ok := err.(*UnexpectedOutputError); !ok {
+				t.Errorf("ParseModelCheckResult(%q) gives %v, expected an unexpected output error", test.result.Term, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("ParseModelCheckResult(%q) fails with %v", test.result.Term, err)
+		} else if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("ParseModelCheckResult(%q) = %+v, expected %+v", test.result.Term, result, test.expected)
+		}
+	}
+}
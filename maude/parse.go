@@ -1,13 +1,15 @@
 package maude
 
 import (
+	"context"
+	"github.com/ningit/smcview/maude/term"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
 // Constants and regular expressions for parsing Maude output
-var noParseRegex = regexp.MustCompile("^no(?:Strat)?Parse\\(([^\\)]+)\\)$")
+var parsedRegex = regexp.MustCompile("^([^ :]+): ")
 
 // ParseOutcome represents the possible outcomes of a parsing operation.
 type ParseOutcome int
@@ -20,93 +22,133 @@ const (
 )
 
 // ParseResult represents a parsing operation result, where Pos tell the
-// position in case of a syntax error.
+// position in case of a syntax error and Messages are the messages printed
+// by Maude about it (if any).
 type ParseResult struct {
-	Type ParseOutcome
-	Pos  int
+	Type     ParseOutcome
+	Pos      int
+	Messages []Message
 }
 
-// Parse tries to parse a term of the given sort in the current module.
-func (c *Client) Parse(term, sort string) ParseResult {
-
-	if !c.active {
-		return ParseResult{Type: GenError}
+// Parse tries to parse the input as a term of the given sort in the current
+// module.
+func (c *Client) Parse(ctx context.Context, input, sort string) (ParseResult, error) {
+	c, release, err := c.acquire(ctx)
+	if err != nil {
+		return ParseResult{Type: GenError}, err
 	}
 
+	defer release()
+
 	// The function should not change the current module
-	var module = c.CurrentModuleName()
-	defer c.Select(module)
+	module, err := c.CurrentModuleName(ctx)
+	if err != nil {
+		return ParseResult{Type: GenError}, err
+	}
+
+	defer c.Select(ctx, module)
 
 	// Tokenize the given term
 	// (backslashes are escaped since we are making a string literal)
-	result := c.ReduceIn("LEXICAL", "tokenize(\""+strings.ReplaceAll(term, "\\", "\\\\")+"\")")
+	result, err := c.ReduceIn(ctx, "LEXICAL", "tokenize(\""+strings.ReplaceAll(input, "\\", "\\\\")+"\")")
 
-	if !result.Ok {
-		return ParseResult{Type: GenError}
+	if err != nil || !result.Ok {
+		return ParseResult{Type: GenError}, err
 	}
 
 	// Parse the tokenized term in the original module
-	result = c.ReduceIn("META-LEVEL", "metaParse(upModule('"+module+
+	result, err = c.ReduceIn(ctx, "META-LEVEL", "metaParse(upModule("+term.Qid(module)+
 		", false), "+result.Term+", '"+sort+")")
 
-	if !result.Ok {
-		return ParseResult{Type: GenError}
+	if err != nil || !result.Ok {
+		return ParseResult{Type: GenError}, err
 	}
 
-	// The parse was not successful if its type is ResultPair?
-	if result.Type == "ResultPair?" {
+	var outcome = parseOutcome(result, "ResultPair?")
 
-		if strings.HasPrefix(result.Term, "ambiguity") {
-			return ParseResult{Type: Ambiguity}
-		}
+	// The parse command explains the syntax errors in the standard error
+	if outcome.Type == NoParse || outcome.Type == Ambiguity {
+		outcome.Messages, err = c.explainParse(ctx, module, input, sort)
+	}
 
-		if match := noParseRegex.FindStringSubmatch(result.Term); match != nil {
-			pos, _ := strconv.Atoi(match[1])
-			return ParseResult{NoParse, pos}
-		}
+	return outcome, err
+}
 
-		return ParseResult{Type: GenError}
+// explainParse executes the parse command on a term that cannot be parsed
+// with the given sort to collect the messages printed by Maude.
+func (c *Client) explainParse(ctx context.Context, module, input, sort string) ([]Message, error) {
+	var command = "parse in " + module + " : " + input + " .\n"
+	var output []string
+
+	messages, err := c.Capture(ctx, func(tx *Client) (err error) {
+		output, err = tx.send(ctx, command)
+		return err
+	})
+
+	// The term is well-formed but it has another sort
+	for _, line := range output {
+		if match := parsedRegex.FindStringSubmatch(line); match != nil && len(messages) == 0 {
+			messages = append(messages, Message{
+				Level: "Error",
+				Text:  "the term has sort " + match[1] + " instead of " + sort + ".",
+			})
+		}
 	}
 
-	return ParseResult{Type: Ok}
+	return messages, err
 }
 
 // StratParse tries a strategy in the current module.
-func (c *Client) StratParse(expr string) ParseResult {
-
-	if !c.active {
-		return ParseResult{Type: GenError}
+func (c *Client) StratParse(ctx context.Context, expr string) (ParseResult, error) {
+	c, release, err := c.acquire(ctx)
+	if err != nil {
+		return ParseResult{Type: GenError}, err
 	}
 
+	defer release()
+
 	// The function should not alter the current module
-	var module = c.CurrentModuleName()
-	defer c.Select(module)
+	module, err := c.CurrentModuleName(ctx)
+	if err != nil {
+		return ParseResult{Type: GenError}, err
+	}
+
+	defer c.Select(ctx, module)
 
 	// Tokenize the given expression
-	result := c.ReduceIn("LEXICAL", "tokenize(\""+expr+"\")")
+	result, err := c.ReduceIn(ctx, "LEXICAL", "tokenize(\""+expr+"\")")
 
-	if !result.Ok {
-		return ParseResult{Type: GenError}
+	if err != nil || !result.Ok {
+		return ParseResult{Type: GenError}, err
 	}
 
 	// Parse the tokenized expression in the original module
-	result = c.ReduceIn("META-LEVEL", "metaParseStrategy(upModule('"+module+
+	result, err = c.ReduceIn(ctx, "META-LEVEL", "metaParseStrategy(upModule("+term.Qid(module)+
 		", false), none, "+result.Term+")")
 
-	if !result.Ok {
-		return ParseResult{Type: GenError}
+	if err != nil || !result.Ok {
+		return ParseResult{Type: GenError}, err
 	}
 
-	// The parse was not successful if its type is Strategy?
-	if result.Type == "Strategy?" {
+	return parseOutcome(result, "Strategy?"), nil
+}
+
+// parseOutcome interprets the result of a meta-level parsing operation,
+// which is not successful if its type is the given error sort.
+func parseOutcome(result ReduceResult, errorSort string) ParseResult {
+	if result.Type == errorSort {
 
 		if strings.HasPrefix(result.Term, "ambiguity") {
 			return ParseResult{Type: Ambiguity}
 		}
 
-		if match := noParseRegex.FindStringSubmatch(result.Term); match != nil {
-			pos, _ := strconv.Atoi(match[1])
-			return ParseResult{NoParse, pos}
+		// The position of the error is given as noParse(pos)
+		if node, err := term.ParsePrefix(result.Term); err == nil &&
+			(node.Is("noParse", 1) || node.Is("noStratParse", 1)) {
+
+			if pos, err := strconv.Atoi(node.Args[0].Symbol); err == nil {
+				return ParseResult{Type: NoParse, Pos: pos}
+			}
 		}
 
 		return ParseResult{Type: GenError}
@@ -0,0 +1,343 @@
+package maude
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed is returned when checking out an interpreter from a pool
+// that has been closed.
+var ErrPoolClosed = errors.New("maude: the interpreter pool is closed")
+
+// Delays between the attempts to replace an interpreter that cannot be
+// started, which grow up to the maximum
+const (
+	replaceDelay    = time.Second
+	maxReplaceDelay = time.Minute
+)
+
+// PoolStats gathers some metrics about the usage of a pool.
+type PoolStats struct {
+	// Size is the number of interpreters in the pool
+	Size int `json:"size"`
+	// Idle is the number of interpreters ready to be checked out
+	Idle int `json:"idle"`
+	// CheckedOut is the number of interpreters currently in use
+	CheckedOut int `json:"checkedOut"`
+	// Checkouts is the number of times an interpreter has been checked out
+	Checkouts int `json:"checkouts"`
+	// Started is the number of interpreters started, including replacements
+	Started int `json:"started"`
+	// Replaced is the number of interpreters replaced after crashing or
+	// failing
+	Replaced int `json:"replaced"`
+	// Failures is the number of interpreters that could not be started
+	Failures int `json:"failures"`
+	// WaitTime is the total time callers have waited for an interpreter
+	WaitTime time.Duration `json:"waitTime"`
+}
+
+// Pool keeps a number of warm interpreters with the same source files
+// loaded. Interpreters are checked out with Get for exclusive use, and
+// checked back in with Put. Those that have crashed, have been killed or
+// have failed while checked out are replaced by fresh ones.
+type Pool struct {
+	maudePath string
+	files     []string
+	// Function to set up every client before it is started
+	setup     func(index int, client *Client)
+	// Interpreters ready to be checked out
+	idle      chan *Client
+	// Closed when the pool is closed
+	done      chan struct{}
+	mutex     sync.Mutex
+	// Index in the pool of every interpreter (passed to the setup function)
+	indices   map[*Client]int
+	closed    bool
+	// Messages printed while loading the files in the first interpreter
+	messages  []Message
+	// Error of the last attempt to start an interpreter (if it failed)
+	lastErr   error
+	stats     PoolStats
+}
+
+// NewPool starts size interpreters of the Maude executable in maudePath
+// and loads the given files in them. The setup function, if not nil, is
+// applied to every client before starting it, with its index in the pool.
+// If any interpreter cannot be started, the others are closed and the
+// error is returned.
+func NewPool(ctx context.Context, maudePath string, size int, files []string, setup func(index int, client *Client)) (*Pool, error) {
+	if size < 1 {
+		size = 1
+	}
+
+	var pool = &Pool{
+		maudePath: maudePath,
+		files:     files,
+		setup:     setup,
+		idle:      make(chan *Client, size),
+		done:      make(chan struct{}),
+		indices:   make(map[*Client]int),
+	}
+
+	// Interpreters are started concurrently
+	var errs = make(chan error, size)
+
+	for i := 0; i < size; i++ {
+		go func(index int) {
+			client, err := pool.startClient(ctx, index)
+
+			if err == nil {
+				pool.idle <- client
+			}
+
+			errs <- err
+		}(i)
+	}
+
+	var err error
+
+	for i := 0; i < size; i++ {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+
+	if err != nil {
+		pool.Close(ctx)
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// startClient starts a new interpreter for the given slot of the pool.
+func (p *Pool) startClient(ctx context.Context, index int) (*Client, error) {
+	var client = InitMaude(p.maudePath)
+
+	if p.setup != nil {
+		p.setup(index, client)
+	}
+
+	var err = client.Start(ctx)
+	var messages = make([]Message, 0)
+
+	for _, file := range p.files {
+		if err != nil {
+			break
+		}
+
+		var fileMessages []Message
+		fileMessages, err = client.LoadChecked(ctx, file)
+		messages = append(messages, fileMessages...)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err != nil {
+		client.Kill()
+		p.stats.Failures++
+		p.lastErr = err
+		return nil, err
+	}
+
+	p.indices[client] = index
+	p.stats.Size++
+	p.stats.Started++
+	p.lastErr = nil
+
+	if index == 0 || p.messages == nil {
+		p.messages = messages
+	}
+
+	return client, nil
+}
+
+// Get checks out an interpreter from the pool, waiting until one is
+// available or the context is done. If no interpreter is left because
+// they cannot be started, the last error is returned.
+func (p *Pool) Get(ctx context.Context) (*Client, error) {
+	var start = time.Now()
+
+	for {
+		p.mutex.Lock()
+		var closed, size, lastErr = p.closed, p.stats.Size, p.lastErr
+		p.mutex.Unlock()
+
+		if closed {
+			return nil, ErrPoolClosed
+		}
+
+		// No interpreter can be expected if all of them have failed
+		if size == 0 && lastErr != nil {
+			return nil, lastErr
+		}
+
+		select {
+		case client := <-p.idle:
+			// Idle interpreters may also crash
+			if !client.Running() {
+				go p.replace(p.remove(client))
+				continue
+			}
+
+			p.mutex.Lock()
+			p.stats.Checkouts++
+			p.stats.CheckedOut++
+			p.stats.WaitTime += time.Since(start)
+			p.mutex.Unlock()
+
+			return client, nil
+
+		case <-p.done:
+			return nil, ErrPoolClosed
+
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Put checks an interpreter back into the pool, with the error of the last
+// operation done with it (if any). If it is no longer running or the
+// operation has failed, it is replaced by a new one in the background,
+// since the failed operation may have left modules, options or unread
+// output behind.
+func (p *Pool) Put(client *Client, err error) {
+	p.mutex.Lock()
+	var _, known = p.indices[client]
+
+	if known {
+		p.stats.CheckedOut--
+	}
+	p.mutex.Unlock()
+
+	// The interpreter does not belong to this pool
+	if !known {
+		return
+	}
+
+	if err == nil && client.Running() {
+		p.checkIn(client)
+	} else {
+		client.Kill()
+		go p.replace(p.remove(client))
+	}
+}
+
+// checkIn makes an interpreter available to be checked out, or quits it if
+// the pool has been closed. The lock is held while it is pushed, so that
+// Close cannot drain the idle interpreters in between and leak it. Pushing
+// does not block because the channel has room for all the interpreters.
+func (p *Pool) checkIn(client *Client) {
+	p.mutex.Lock()
+
+	if !p.closed {
+		p.idle <- client
+		p.mutex.Unlock()
+		return
+	}
+
+	p.mutex.Unlock()
+	quitInterpreter(client)
+}
+
+// remove forgets about an interpreter of the pool and returns its index.
+func (p *Pool) remove(client *Client) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var index = p.indices[client]
+	delete(p.indices, client)
+	p.stats.Size--
+
+	return index
+}
+
+// replace starts a new interpreter in the given slot in place of a
+// crashed or failed one. If it cannot be started, it is retried with
+// increasing delays until the pool is closed, so that the pool does not
+// shrink.
+func (p *Pool) replace(index int) {
+	log.Print("replacing a Maude interpreter in the pool")
+
+	var delay = replaceDelay
+
+	for {
+		replacement, err := p.startClient(context.Background(), index)
+
+		if err == nil {
+			p.mutex.Lock()
+			p.stats.Replaced++
+			p.mutex.Unlock()
+
+			p.checkIn(replacement)
+			return
+		}
+
+		log.Print("cannot replace the Maude interpreter (retrying in ", delay, "): ", err)
+
+		select {
+		case <-time.After(delay):
+		case <-p.done:
+			return
+		}
+
+		if delay *= 2; delay > maxReplaceDelay {
+			delay = maxReplaceDelay
+		}
+	}
+}
+
+// Messages returns the messages printed by Maude while loading the files
+// of the pool.
+func (p *Pool) Messages() []Message {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.messages
+}
+
+// Stats returns the current metrics of the pool.
+func (p *Pool) Stats() PoolStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var stats = p.stats
+	stats.Idle = len(p.idle)
+
+	return stats
+}
+
+// Close quits the idle interpreters of the pool. Those checked out are
+// quit when they are checked in.
+func (p *Pool) Close(ctx context.Context) {
+	p.mutex.Lock()
+	if p.closed {
+		p.mutex.Unlock()
+		return
+	}
+	p.closed = true
+	close(p.done)
+	p.mutex.Unlock()
+
+	for {
+		select {
+		case client := <-p.idle:
+			client.Quit(ctx)
+		default:
+			return
+		}
+	}
+}
+
+// quitInterpreter quits an interpreter that is no longer needed.
+func quitInterpreter(client *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	client.Quit(ctx)
+	cancel()
+}
@@ -0,0 +1,122 @@
+package maude
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+// poolInterpreter is a fake interpreter that cannot be started while a
+// file named like it with the .fail extension exists.
+const poolInterpreter = `[ -e "$0.fail" ] && exit 1
+printf 'Maude> '
+exec cat > /dev/null
+`
+
+func TestPutFailed(t *testing.T) {
+	path, cleanup := fakeScript(t, poolInterpreter)
+	defer cleanup()
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pool, err := NewPool(ctx, path, 1, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer pool.Close(context.Background())
+
+	// An interpreter checked in without errors is reused
+	first, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool.Put(first, nil)
+
+	if client, err := pool.Get(ctx); err != nil {
+		t.Fatal(err)
+	} else if client != first {
+		t.Error("a working interpreter has been replaced")
+	}
+
+	// The interpreter is replaced after a failed operation
+	pool.Put(first, errors.New("failed operation"))
+
+	second, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second == first {
+		t.Error("a failed interpreter has been checked out again")
+	}
+
+	if first.Running() {
+		t.Error("a failed interpreter is still running")
+	}
+
+	if stats := pool.Stats(); stats.Size != 1 || stats.Replaced != 1 {
+		t.Errorf("the stats of the pool are %+v, expected a single replacement", stats)
+	}
+
+	pool.Put(second, nil)
+}
+
+func TestReplaceRetry(t *testing.T) {
+	path, cleanup := fakeScript(t, poolInterpreter)
+	defer cleanup()
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pool, err := NewPool(ctx, path, 1, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer pool.Close(context.Background())
+
+	client, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The replacement cannot be started for a while
+	if err := ioutil.WriteFile(path+".fail", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client.Kill()
+	pool.Put(client, nil)
+
+	for pool.Stats().Failures == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The error is reported while no interpreter is left
+	if _, err := pool.Get(ctx); err == nil {
+		t.Error("no error is reported when the interpreters cannot be started")
+	}
+
+	os.Remove(path + ".fail")
+
+	// The replacement is retried until it succeeds
+	for pool.Stats().Size == 0 && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if client, err = pool.Get(ctx); err != nil {
+		t.Fatalf("the interpreter has not been replaced: %v", err)
+	}
+
+	if stats := pool.Stats(); stats.Size != 1 || stats.Replaced != 1 {
+		t.Errorf("the stats of the pool are %+v, expected a single replacement", stats)
+	}
+
+	pool.Put(client, nil)
+}
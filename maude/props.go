@@ -0,0 +1,197 @@
+package maude
+
+import (
+	"context"
+	"github.com/ningit/smcview/maude/term"
+	"strings"
+)
+
+// PropValue is the truth value of an atomic proposition in a state.
+type PropValue int
+
+const (
+	// PropUnknown is the value of the propositions that cannot be evaluated
+	PropUnknown PropValue = iota
+	PropFalse
+	PropTrue
+)
+
+func (v PropValue) String() string {
+	switch v {
+		case PropFalse : return "false"
+		case PropTrue  : return "true"
+		default        : return "unknown"
+	}
+}
+
+// PropTable is the truth table of the atomic propositions of a formula in
+// some states.
+type PropTable struct {
+	// Props are the atomic propositions as printed by Maude
+	Props  []string
+	// Values[i][j] is the value of the j-th proposition in the i-th state
+	Values [][]PropValue
+}
+
+// Name of the module where atomic propositions are evaluated by EvalProps,
+// constant of sort Prop used as error value for downTerm, and constructors
+// that collect the values of the propositions in a state, to evaluate them
+// all in a single command
+const (
+	propsModule = "%SMCVIEW-PROPS"
+	noProp      = "%smcview-noprop"
+	propItem    = "%smcview-prop("
+	propEnd     = "%smcview-end"
+	propsDecls  = `	op %smcview-noprop : -> Prop .
+	sort %SmcviewProps .
+	op %smcview-end : -> %SmcviewProps [ctor] .
+	op %smcview-prop(_)_ : Bool %SmcviewProps -> %SmcviewProps [ctor] .
+`
+)
+
+// Connectives of the LTL module, whose arguments are inspected to find the
+// atomic propositions of a formula
+var ltlConnectives = map[string]bool{
+	"True": true, "False": true, "~_": true, "_/\\_": true, "_\\/_": true,
+	"O_": true, "_U_": true, "_R_": true, "_W_": true, "<>_": true, "[]_": true,
+	"_->_": true, "_<->_": true, "_|->_": true, "_=>_": true, "_<=>_": true,
+}
+
+// EvalProps evaluates the atomic propositions of an LTL formula in some state
+// terms by reducing term |= prop in the given module (for all propositions of
+// a state in a single command). The result is nil if the formula or the
+// propositions cannot be handled in the module (because it does not include
+// SATISFACTION, for example). Propositions whose value is neither true nor
+// false in a state are PropUnknown there.
+func (c *Client) EvalProps(ctx context.Context, module, formula string, terms []string) (*PropTable, error) {
+	c, release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	_, ok, err := c.DeclareWrapper(ctx, propsModule, metaWrapperImports(module), propsDecls)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	// The propositions are found in the metarepresentation of the formula
+	result, err := c.ReduceIn(ctx, propsModule, "upTerm(("+formula+"))")
+	if err != nil || !result.Ok {
+		return nil, err
+	}
+
+	metaFormula, err := term.Parse(result.Term)
+	if err != nil {
+		return nil, nil
+	}
+
+	// Propositions in the form they are evaluated
+	var downProps = make([]string, 0)
+	var table = &PropTable{Props: make([]string, 0), Values: make([][]PropValue, len(terms))}
+
+	for _, prop := range atomicProps(metaFormula, make(map[string]bool), nil) {
+		// Propositions are evaluated at the object level after downTerm
+		// to avoid parsing them again
+		var downProp = "downTerm(" + prop.String() + ", " + noProp + ")"
+
+		result, err := c.ReduceIn(ctx, propsModule, downProp)
+		if err != nil {
+			return nil, err
+		}
+
+		if !result.Ok || result.Term == noProp {
+			continue
+		}
+
+		downProps = append(downProps, downProp)
+		table.Props = append(table.Props, result.Term)
+	}
+
+	for i, input := range terms {
+		if table.Values[i], err = c.evalStateProps(ctx, input, downProps); err != nil {
+			return nil, err
+		}
+	}
+
+	return table, nil
+}
+
+// evalStateProps evaluates some propositions in a state with a single
+// reduction, or one by one if some of them cannot be parsed in that state.
+func (c *Client) evalStateProps(ctx context.Context, state string, props []string) ([]PropValue, error) {
+	var values = make([]PropValue, len(props))
+
+	if len(props) == 0 {
+		return values, nil
+	}
+
+	var builder strings.Builder
+
+	for _, prop := range props {
+		builder.WriteString(propItem + "(" + state + ") |= " + prop + ") ")
+	}
+
+	builder.WriteString(propEnd)
+
+	result, err := c.ReduceIn(ctx, propsModule, builder.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// The result is %smcview-prop(v1) ... %smcview-prop(vn) %smcview-end
+	if result.Ok && strings.HasSuffix(result.Term, propEnd) {
+		var items = strings.Split(strings.TrimSuffix(result.Term, propEnd), propItem)
+
+		if len(items) == len(props)+1 && strings.TrimSpace(items[0]) == "" {
+			for j, item := range items[1:] {
+				item = strings.TrimSpace(item)
+				values[j] = propValue(strings.TrimSuffix(item, ")"))
+			}
+
+			return values, nil
+		}
+	}
+
+	for j, prop := range props {
+		result, err := c.ReduceIn(ctx, propsModule, "("+state+") |= "+prop)
+		if err != nil {
+			return nil, err
+		}
+
+		if result.Ok && result.Type == "Bool" {
+			values[j] = propValue(result.Term)
+		}
+	}
+
+	return values, nil
+}
+
+// propValue converts the result of evaluating a proposition to its value.
+func propValue(result string) PropValue {
+	switch result {
+		case "true"  : return PropTrue
+		case "false" : return PropFalse
+		default      : return PropUnknown
+	}
+}
+
+// atomicProps collects the subterms of a metarepresented formula that are not
+// LTL connectives, in order of appearance and without repetitions.
+func atomicProps(formula *term.Term, seen map[string]bool, props []*term.Term) []*term.Term {
+	if ltlConnectives[formula.Name] && formula.Kind != term.Variable {
+		for _, arg := range formula.Args {
+			props = atomicProps(arg, seen, props)
+		}
+
+		return props
+	}
+
+	if key := formula.String(); !seen[key] {
+		seen[key] = true
+		props = append(props, formula)
+	}
+
+	return props
+}
@@ -1,66 +1,93 @@
 package maude
 
 import (
+	"context"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Constants and regular expressions for parsing Maude output
-var resultRegex  = regexp.MustCompile("^result ([^:]+): (.*)\n$")
+var (
+	resultRegex = regexp.MustCompile("^result ([^:]+): (.*)$")
+	statsRegex  = regexp.MustCompile("^(?:states: ([0-9]+)  )?rewrites: ([0-9]+) in (-?[0-9]+)ms cpu \\((-?[0-9]+)ms real\\)")
+)
+
+// Stats are the statistics printed by Maude after executing a command.
+type Stats struct {
+	// Available tells whether the statistics have been printed
+	Available bool          `json:"available"`
+	// States is the number of states explored by search (zero otherwise)
+	States    int           `json:"states"`
+	Rewrites  int64         `json:"rewrites"`
+	CpuTime   time.Duration `json:"cpuTime"`
+	RealTime  time.Duration `json:"realTime"`
+}
+
+// parseStats parses a statistics line, returning whether it is one.
+func parseStats(line string, stats *Stats) bool {
+	var match = statsRegex.FindStringSubmatch(line)
+
+	if match == nil {
+		return false
+	}
+
+	states, _ := strconv.Atoi(match[1])
+	rewrites, _ := strconv.ParseInt(match[2], 10, 64)
+	cpuTime, _ := strconv.ParseInt(match[3], 10, 64)
+	realTime, _ := strconv.ParseInt(match[4], 10, 64)
+
+	*stats = Stats{true, states, rewrites, time.Duration(cpuTime) * time.Millisecond,
+		time.Duration(realTime) * time.Millisecond}
+
+	return true
+}
 
-// ReduceResult describes the result of a reduction in Maude.
+// ReduceResult describes the result of a reduction in Maude. Ok is false
+// when Maude has not produced any result, because the input term is not
+// valid for example.
 type ReduceResult struct {
-	Ok   bool
-	Term string
-	Type string
+	Ok    bool
+	Term  string
+	Type  string
+	Stats Stats
 }
 
 // Reduce reduces a term in the current module.
-func (c *Client) Reduce(term string) ReduceResult {
-	return c.reduce("red " + term + " .\n")
+func (c *Client) Reduce(ctx context.Context, term string) (ReduceResult, error) {
+	return c.reduce(ctx, "red "+term+" .\n")
 }
 
 // Reduce reduces a term in the given module.
-func (c *Client) ReduceIn(module, term string) ReduceResult {
-	return c.reduce("red in " + module + " : " + term + " .\n")
+func (c *Client) ReduceIn(ctx context.Context, module, term string) (ReduceResult, error) {
+	return c.reduce(ctx, "red in "+module+" : "+term+" .\n")
 }
 
-func (c *Client) reduce(command string) ReduceResult {
+func (c *Client) reduce(ctx context.Context, command string) (ReduceResult, error) {
 	var result = ReduceResult{Ok: false}
 
-	if !c.active {
-		return result
+	lines, err := c.exchange(ctx, command)
+	if err != nil {
+		return result, err
 	}
 
-	// At the moment, the rewriting count and similar data is ignored
-	c.stdin.Write([]byte(command))
-
-	for !c.promptReached() {
-		str, _ := c.stdout.ReadString('\n')
+	for i, line := range lines {
+		// The statistics line precedes the result
+		if parseStats(line, &result.Stats) {
+			continue
+		}
 
-		var match = resultRegex.FindStringSubmatch(str)
+		var match = resultRegex.FindStringSubmatch(line)
 
 		if match != nil {
 			result.Ok = true
 			result.Type = match[1]
-			result.Term = match[2]
+			// Terms can span multiple lines because of format
+			result.Term = strings.TrimRight(strings.Join(append([]string{match[2]}, lines[i+1:]...), "\n"), "\n")
 			break
 		}
 	}
 
-	// Terms can span multiple lines because of format
-	if !c.promptReached() {
-		result.Term += "\n"
-
-		for !c.promptReached() {
-			line, _ := c.stdout.ReadString('\n')
-			result.Term += line
-		}
-
-		result.Term = strings.TrimSuffix(result.Term, "\n")
-	}
-
-	c.advanceUntilPrompt()
-
-	return result
+	return result, nil
 }
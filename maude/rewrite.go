@@ -0,0 +1,240 @@
+package maude
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+)
+
+// Constants and regular expressions for parsing Maude output
+var (
+	solutionRegex = regexp.MustCompile("^Solution ([0-9]+)(?: \\(state ([0-9]+)\\))?$")
+	bindingRegex  = regexp.MustCompile("^([^ ]+) --> (.*)$")
+)
+
+// SearchArrow is the relation between the initial term and the solutions
+// of a search.
+type SearchArrow string
+
+const (
+	// OneStep looks for terms reachable in exactly one step
+	OneStep SearchArrow = "=>1"
+	// OneOrMoreSteps looks for terms reachable in one or more steps
+	OneOrMoreSteps SearchArrow = "=>+"
+	// AnySteps looks for terms reachable in zero or more steps
+	AnySteps SearchArrow = "=>*"
+	// NormalForm looks for reachable terms that cannot be further rewritten
+	NormalForm SearchArrow = "=>!"
+)
+
+// SearchQuery describes a search command.
+type SearchQuery struct {
+	// Module where the search is done (the current one if empty)
+	Module    string
+	Initial   string
+	Arrow     SearchArrow
+	Pattern   string
+	// Condition is the such that clause (none if empty)
+	Condition string
+	// MaxSolutions and MaxDepth are the bounds of the search (zero means
+	// unbounded)
+	MaxSolutions int
+	MaxDepth     int
+}
+
+// Binding is the value assigned to a variable in a substitution.
+type Binding struct {
+	Variable string `json:"variable"`
+	Value    string `json:"value"`
+}
+
+// Solution is a solution of a search or strategy rewriting command.
+type Solution struct {
+	Number int `json:"number"`
+	// State is the number of the solution state in the search graph
+	// (or -1 if not given)
+	State  int `json:"state"`
+	// Term and Type are the solution of strategy rewriting commands
+	Term   string `json:"term"`
+	Type   string `json:"type"`
+	// Substitution are the matching substitutions of search commands
+	Substitution []Binding `json:"substitution"`
+	Stats        Stats     `json:"stats"`
+}
+
+// SearchResult is the result of a search or strategy rewriting command.
+type SearchResult struct {
+	// Ok is false when the command has not been executed, because its
+	// input is not valid for example
+	Ok        bool       `json:"ok"`
+	Solutions []Solution `json:"solutions"`
+	// Complete tells whether all solutions have been found (it is false
+	// if the command has stopped because of the bounds)
+	Complete  bool       `json:"complete"`
+	// Stats are the global statistics of the command
+	Stats     Stats      `json:"stats"`
+}
+
+// inModule prefixes a term with the module where a command is executed.
+func inModule(module, term string) string {
+	if module == "" {
+		return term
+	}
+
+	return "in " + module + " : " + term
+}
+
+// Rewrite rewrites a term in the given module (or the current one if
+// empty) with at most bound rewrites (unbounded if zero).
+func (c *Client) Rewrite(ctx context.Context, module, term string, bound int) (ReduceResult, error) {
+	var command = "rew "
+
+	if bound > 0 {
+		command += "[" + strconv.Itoa(bound) + "] "
+	}
+
+	return c.reduce(ctx, command+inModule(module, term)+" .\n")
+}
+
+// Frewrite rewrites a term with the fair rewriting strategy in the given
+// module (or the current one if empty), with at most bound rule applications
+// (unbounded if zero) and gas rewrites at each position (default if zero, and
+// only taken into account if the number of applications is bounded).
+func (c *Client) Frewrite(ctx context.Context, module, term string, bound, gas int) (ReduceResult, error) {
+	var command = "frew "
+
+	if bound > 0 {
+		command += "[" + strconv.Itoa(bound)
+
+		if gas > 0 {
+			command += ", " + strconv.Itoa(gas)
+		}
+
+		command += "] "
+	}
+
+	return c.reduce(ctx, command+inModule(module, term)+" .\n")
+}
+
+// Search explores the rewriting graph of a term looking for the terms
+// that match a pattern.
+func (c *Client) Search(ctx context.Context, query SearchQuery) (SearchResult, error) {
+	var command = "search "
+
+	if query.MaxSolutions > 0 || query.MaxDepth > 0 {
+		command += "["
+
+		if query.MaxSolutions > 0 {
+			command += strconv.Itoa(query.MaxSolutions)
+		}
+
+		if query.MaxDepth > 0 {
+			command += ", " + strconv.Itoa(query.MaxDepth)
+		}
+
+		command += "] "
+	}
+
+	var arrow = query.Arrow
+
+	if arrow == "" {
+		arrow = AnySteps
+	}
+
+	command += inModule(query.Module, query.Initial) + " " + string(arrow) + " " + query.Pattern
+
+	if query.Condition != "" {
+		command += " such that " + query.Condition
+	}
+
+	return c.search(ctx, command+" .\n")
+}
+
+// Srewrite rewrites a term in the given module (or the current one if empty)
+// according to a strategy, looking for at most maxSolutions solutions (all
+// of them if zero).
+func (c *Client) Srewrite(ctx context.Context, module, term, strategy string, maxSolutions int) (SearchResult, error) {
+	return c.search(ctx, strategyCommand("srew", module, term, strategy, maxSolutions))
+}
+
+// Dsrewrite is like Srewrite but the strategy is executed in depth.
+func (c *Client) Dsrewrite(ctx context.Context, module, term, strategy string, maxSolutions int) (SearchResult, error) {
+	return c.search(ctx, strategyCommand("dsrew", module, term, strategy, maxSolutions))
+}
+
+func strategyCommand(name, module, term, strategy string, maxSolutions int) string {
+	var command = name + " "
+
+	if maxSolutions > 0 {
+		command += "[" + strconv.Itoa(maxSolutions) + "] "
+	}
+
+	return command + inModule(module, term) + " using " + strategy + " .\n"
+}
+
+// search executes a command whose output is a sequence of solutions.
+func (c *Client) search(ctx context.Context, command string) (SearchResult, error) {
+	lines, err := c.exchange(ctx, command)
+	if err != nil {
+		return SearchResult{Solutions: make([]Solution, 0)}, err
+	}
+
+	return parseSearch(lines), nil
+}
+
+// parseSearch parses the output of a search or strategy rewriting command.
+func parseSearch(lines []string) SearchResult {
+	var result = SearchResult{Solutions: make([]Solution, 0)}
+
+	// The solution being read (nil after the last one)
+	var current *Solution
+
+	for i := 0; i < len(lines); i++ {
+		var line = lines[i]
+
+		if match := solutionRegex.FindStringSubmatch(line); match != nil {
+			var number, _ = strconv.Atoi(match[1])
+			var state = -1
+
+			if match[2] != "" {
+				state, _ = strconv.Atoi(match[2])
+			}
+
+			result.Solutions = append(result.Solutions, Solution{
+				Number:       number,
+				State:        state,
+				Substitution: make([]Binding, 0),
+			})
+			current = &result.Solutions[len(result.Solutions)-1]
+			result.Ok = true
+
+		} else if line == "No solution." || line == "No more solutions." {
+			current = nil
+			result.Ok = true
+			result.Complete = true
+
+		} else if current == nil {
+			// Global statistics after the last solution
+			parseStats(line, &result.Stats)
+
+		} else if parseStats(line, &current.Stats) {
+			// The statistics of each solution are cumulative
+			result.Stats = current.Stats
+
+		} else if match := resultRegex.FindStringSubmatch(line); match != nil {
+			current.Type = match[1]
+			current.Term = match[2]
+
+			// Terms can span multiple lines because of format
+			for i+1 < len(lines) && lines[i+1] != "" {
+				i++
+				current.Term += "\n" + lines[i]
+			}
+
+		} else if match := bindingRegex.FindStringSubmatch(line); match != nil {
+			current.Substitution = append(current.Substitution, Binding{match[1], match[2]})
+		}
+	}
+
+	return result
+}
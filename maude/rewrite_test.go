@@ -0,0 +1,138 @@
+package maude
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStats builds the statistics of a solution for the tests
+func testStats(states int, rewrites int64, cpu, real int) Stats {
+	return Stats{true, states, rewrites, time.Duration(cpu) * time.Millisecond, time.Duration(real) * time.Millisecond}
+}
+
+func TestParseSearch(t *testing.T) {
+	var tests = []struct {
+		name     string
+		output   string
+		expected SearchResult
+	}{
+		{"search", `search in NAT-COUNTER : 0 =>* N:Nat such that N:Nat < 3 .
+
+Solution 1 (state 0)
+states: 1  rewrites: 1 in 0ms cpu (0ms real) (~ rewrites/second)
+N:Nat --> 0
+
+Solution 2 (state 1)
+states: 2  rewrites: 3 in 0ms cpu (0ms real) (~ rewrites/second)
+N:Nat --> 1
+
+Solution 3 (state 2)
+states: 3  rewrites: 5 in 1ms cpu (1ms real) (5000 rewrites/second)
+N:Nat --> 2
+
+No more solutions.
+states: 4  rewrites: 7 in 1ms cpu (2ms real) (7000 rewrites/second)`,
+			SearchResult{
+				Ok: true,
+				Solutions: []Solution{
+					{Number: 1, State: 0, Substitution: []Binding{{"N:Nat", "0"}}, Stats: testStats(1, 1, 0, 0)},
+					{Number: 2, State: 1, Substitution: []Binding{{"N:Nat", "1"}}, Stats: testStats(2, 3, 0, 0)},
+					{Number: 3, State: 2, Substitution: []Binding{{"N:Nat", "2"}}, Stats: testStats(3, 5, 1, 1)},
+				},
+				Complete: true,
+				Stats:    testStats(4, 7, 1, 2),
+			}},
+		{"bindings", `Solution 1 (state 4)
+states: 5  rewrites: 12 in 0ms cpu (0ms real) (~ rewrites/second)
+L:List --> a b c
+S:String --> "x --> y"
+E:Elt --> < 'a, "b" >`,
+			SearchResult{
+				Ok: true,
+				Solutions: []Solution{
+					{Number: 1, State: 4, Substitution: []Binding{
+						{"L:List", "a b c"}, {"S:String", `"x --> y"`}, {"E:Elt", `< 'a, "b" >`},
+					}, Stats: testStats(5, 12, 0, 0)},
+				},
+				Complete: false,
+				Stats:    testStats(5, 12, 0, 0),
+			}},
+		{"empty substitution", `search [1] in NAT-COUNTER : 0 =>+ 1 .
+
+Solution 1 (state 1)
+states: 2  rewrites: 1 in 0ms cpu (0ms real) (~ rewrites/second)
+empty substitution
+`,
+			SearchResult{
+				Ok: true,
+				Solutions: []Solution{
+					{Number: 1, State: 1, Substitution: []Binding{}, Stats: testStats(2, 1, 0, 0)},
+				},
+				Stats: testStats(2, 1, 0, 0),
+			}},
+		{"no solution", `search in NAT-COUNTER : 0 =>! 5 .
+
+No solution.
+states: 3  rewrites: 4 in 0ms cpu (0ms real) (~ rewrites/second)`,
+			SearchResult{
+				Ok:        true,
+				Solutions: []Solution{},
+				Complete:  true,
+				Stats:     testStats(3, 4, 0, 0),
+			}},
+		{"srewrite", `srewrite in NAT-COUNTER : 0 using inc * .
+
+Solution 1
+rewrites: 0 in 0ms cpu (0ms real) (~ rewrites/second)
+result Zero: 0
+
+Solution 2
+rewrites: 1 in 0ms cpu (0ms real) (~ rewrites/second)
+result NzNat: 1
+
+No more solutions.
+rewrites: 2 in 0ms cpu (1ms real) (~ rewrites/second)`,
+			SearchResult{
+				Ok: true,
+				Solutions: []Solution{
+					{Number: 1, State: -1, Term: "0", Type: "Zero", Substitution: []Binding{}, Stats: testStats(0, 0, 0, 0)},
+					{Number: 2, State: -1, Term: "1", Type: "NzNat", Substitution: []Binding{}, Stats: testStats(0, 1, 0, 0)},
+				},
+				Complete: true,
+				Stats:    testStats(0, 2, 0, 1),
+			}},
+		// Terms printed with format attributes may span several lines
+		{"multiline", `Solution 1
+rewrites: 3 in 0ms cpu (0ms real) (~ rewrites/second)
+result Board: row(1 2)
+    row(3 4)
+    row(5 6)
+
+No more solutions.
+rewrites: 3 in 0ms cpu (0ms real) (~ rewrites/second)`,
+			SearchResult{
+				Ok: true,
+				Solutions: []Solution{
+					{Number: 1, State: -1, Term: "row(1 2)\n    row(3 4)\n    row(5 6)", Type: "Board",
+						Substitution: []Binding{}, Stats: testStats(0, 3, 0, 0)},
+				},
+				Complete: true,
+				Stats:    testStats(0, 3, 0, 0),
+			}},
+		{"invalid", `Warning: <standard input>, line 1: didn't expect token foo:
+srewrite in NAT-COUNTER : foo <---
+Warning: <standard input>, line 1: no parse for term.`,
+			SearchResult{Solutions: []Solution{}}},
+		{"empty", ``, SearchResult{Solutions: []Solution{}}},
+	}
+
+	for _, test := range tests {
+		var result = parseSearch(strings.Split(test.output, "\n"))
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("%s: parseSearch gives %+v, expected %+v", test.name, result, test.expected)
+		}
+	}
+}
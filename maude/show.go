@@ -1,16 +1,17 @@
 package maude
 
 import (
+	"context"
+	"github.com/ningit/smcview/maude/term"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
 // Constants and regular expressions for parsing Maude output
 var (
-	moddeclRegex = regexp.MustCompile("^(fmod|mod|smod|fth|th|sth) ([^ {]+)(?:{([^}]*)})? is\n$")
-	sortRegex    = regexp.MustCompile("^sort ([^ ]+) .")
-	stratRegex   = regexp.MustCompile("^strat ([^ ]+)(?: : ([^@]+))? @ ([^ ]+)")
-	propRegex    = regexp.MustCompile("^op ([^ ]+) : (.*)-> ([^ ]+)")
+	moddeclRegex = regexp.MustCompile("^(fmod|mod|smod|fth|th|sth) ([^ {]+)(?:{([^}]*)})? is$")
+	viewRegex    = regexp.MustCompile("^view ([^ {]+)$")
 )
 
 
@@ -29,134 +30,197 @@ type ExtendedModuleInfo struct {
 
 // Modules returns all modules and theories defined in the current Maude
 // session. Instantiated and renamed modules are ignored.
-func (c *Client) Modules() []ModuleInfo {
-	if !c.active {
-		return nil
+func (c *Client) Modules(ctx context.Context) ([]ModuleInfo, error) {
+	lines, err := c.exchange(ctx, "show modules .\n")
+	if err != nil {
+		return nil, err
 	}
 
-	c.stdin.Write([]byte("show modules .\n"))
-
 	var modules = make([]ModuleInfo, 0)
 
-	for !c.promptReached() {
-		str, _ := c.stdout.ReadString('\n')
-
-		if match := modRegex.FindStringSubmatch(str); match != nil {
+	for _, line := range lines {
+		if match := modRegex.FindStringSubmatch(line); match != nil {
 			modules = append(modules, ModuleInfo{match[2], match[1]})
 		}
 	}
 
-	c.advanceUntilPrompt()
+	return modules, nil
+}
+
+// UpModule returns the signature of a module (flattened with its imports
+// if flat is set), obtained from its metarepresentation. Nil is returned if
+// the module does not exist.
+func (c *Client) UpModule(ctx context.Context, name string, flat bool) (*term.Module, error) {
+	var command = "upModule(" + term.Qid(name) + ", " + strconv.FormatBool(flat) + ")"
+
+	results, err := c.metaLevel(ctx, func(string) []string {
+		return []string{command}
+	})
+
+	if err != nil {
+		return nil, err
+	}
 
-	return modules
+	// Unknown modules are not reduced
+	if results[0].Is("upModule", 2) {
+		return nil, nil
+	}
+
+	module, err := term.ModuleFromNode(results[0])
+	if err != nil {
+		return nil, &UnexpectedOutputError{"red in META-LEVEL : " + command + " .", results[0].String()}
+	}
+
+	return module, nil
 }
 
 // GetModInfo provides information about a module including
 // its parameter theories.
-func (c *Client) GetModInfo(name string) ExtendedModuleInfo {
+func (c *Client) GetModInfo(ctx context.Context, name string) (ExtendedModuleInfo, error) {
 	var modinfo = ExtendedModuleInfo{ModuleInfo: ModuleInfo{Name: name}}
 
-	if !c.active {
-		return modinfo
+	module, err := c.UpModule(ctx, name, false)
+
+	// Unknown modules are just ignored
+	if err != nil || module == nil {
+		return modinfo, err
 	}
 
-	c.stdin.Write([]byte("show module " + name + " .\n"))
-	str, _ := c.stdout.ReadString('\n')
+	modinfo.Type = module.Type
+	modinfo.Params = make([]string, len(module.Params))
 
-	if match := moddeclRegex.FindStringSubmatch(str); match != nil {
-		modinfo.Type = match[1]
+	for i, param := range module.Params {
+		// Only the theory name is stored
+		modinfo.Params[i] = param.Theory
+	}
 
-		if match[3] == "" {
-			// In case there is no braces
-			modinfo.Params = make([]string, 0)
-		} else {
-			modinfo.Params = strings.Split(match[3], ", ")
+	return modinfo, nil
+}
 
-			for i, value := range modinfo.Params {
-				// Drops the parameter name to store only the theory name
-				var index = strings.LastIndex(value, " :: ") + 4
-				modinfo.Params[i] = value[index:]
-			}
-		}
+// Views returns all views defined in the current Maude session, with the
+// theory and module they map. Parameterized views are ignored.
+func (c *Client) Views(ctx context.Context) ([]term.View, error) {
+	lines, err := c.exchange(ctx, "show views .\n")
+	if err != nil {
+		return nil, err
 	}
 
-	c.advanceUntilPrompt()
-
-	return modinfo
-}
+	var inputs = make([]string, 0)
 
-// Sorts returns all sorts defined in the current modules and its imports.
-func (c *Client) Sorts() []string {
-	if !c.active {
-		return nil
+	for _, line := range lines {
+		if match := viewRegex.FindStringSubmatch(line); match != nil {
+			inputs = append(inputs, "upView("+term.Qid(match[1])+")")
+		}
 	}
 
-	c.stdin.Write([]byte("show sorts .\n"))
+	results, err := c.metaLevel(ctx, func(string) []string {
+		return inputs
+	})
 
-	var sorts = make([]string, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	for !c.promptReached() {
-		str, _ := c.stdout.ReadString('\n')
+	var views = make([]term.View, 0, len(results))
 
-		var match = sortRegex.FindStringSubmatch(str)
-		sorts = append(sorts, match[1])
+	for _, result := range results {
+		// Parameterized views and those that cannot be metarepresented are ignored
+		if view, err := term.ViewFromNode(result); err == nil && !strings.Contains(view.Name, "{") {
+			views = append(views, *view)
+		}
 	}
 
-	c.advanceUntilPrompt()
+	return views, nil
+}
+
+// Sorts returns all sorts defined in the current modules and its imports.
+func (c *Client) Sorts(ctx context.Context) ([]string, error) {
+	results, err := c.metaLevel(ctx, func(module string) []string {
+		return []string{"getSorts(upModule(" + module + ", true))"}
+	})
+
+	if err != nil {
+		return nil, err
+	}
 
-	return sorts
+	return term.SortSet(results[0]), nil
 }
 
 // Subsorts returns all sub- and supersorts of a given sort
 // in the current module.
-func (c *Client) Subsorts(sort string) ([]string, []string) {
-	if !c.active {
-		return nil, nil
+func (c *Client) Subsorts(ctx context.Context, sort string) ([]string, []string, error) {
+	results, err := c.metaLevel(ctx, func(module string) []string {
+		return []string{
+			"getSorts(upModule(" + module + ", true))",
+			"getSubsorts(upModule(" + module + ", true))",
+		}
+	})
+
+	if err != nil {
+		return nil, nil, err
 	}
 
-	c.stdin.Write([]byte("show sorts .\n"))
-	defer c.advanceUntilPrompt()
+	var sorts = term.SortSet(results[0])
+	var found = false
 
-	// The command show sorts produces lines of the form:
-	//	sort S . subsorts sub < S < sup .
-	// The sub and sup parts or the whole subsorts block
-	// are omitted when empty.
-	var prefix = "sort " + sort + " ."
+	for _, name := range sorts {
+		if name == sort {
+			found = true
+			break
+		}
+	}
 
-	for !c.promptReached() {
-		str, _ := c.stdout.ReadString('\n')
+	// The sort does not exist
+	if !found {
+		return nil, nil, nil
+	}
 
-		// The required sort was found
-		if strings.HasPrefix(str, prefix) {
+	// Only the direct subsort declarations are given, so the relation
+	// is transitively closed
+	var greater = make(map[string][]string)
 
-			var sub = make([]string, 0)
-			var super = make([]string, 0)
+	for _, decl := range term.SubsortDecls(results[1]) {
+		greater[decl.Lower] = append(greater[decl.Lower], decl.Upper)
+	}
 
-			// There is a subsort declaration
-			if len(str) > len(prefix)+10 {
-				// Only the subsort part is retained
-				str = str[len(prefix)+9 : len(str)-2]
+	// Whether the first sort is strictly below the second
+	var below = func(lower, upper string) bool {
+		var visited = map[string]bool{lower: true}
+		var pending = []string{lower}
+
+		for len(pending) > 0 {
+			var current = pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+
+			for _, next := range greater[current] {
+				if next == upper {
+					return true
+				} else if !visited[next] {
+					visited[next] = true
+					pending = append(pending, next)
+				}
+			}
+		}
 
-				// Look for the < signs as anchors
-				var less = strings.IndexByte(str, '<')
-				var sortIndex = strings.Index(str, " "+sort+" ")
-				var greater = strings.LastIndexByte(str, '<')
+		return false
+	}
 
-				if less < sortIndex {
-					sub = strings.Split(str[:less-1], " ")
-				}
+	var sub = make([]string, 0)
+	var super = make([]string, 0)
 
-				if greater > sortIndex {
-					super = strings.Split(str[greater+2:], " ")
-				}
-			}
+	for _, name := range sorts {
+		if name == sort {
+			continue
+		}
 
-			return sub, super
+		if below(name, sort) {
+			sub = append(sub, name)
+		} else if below(sort, name) {
+			super = append(super, name)
 		}
 	}
 
-	// The sort does not exist
-	return nil, nil
+	return sub, super, nil
 }
 
 // NamedStrategy describes a strategy declaration with its name,
@@ -169,32 +233,22 @@ type NamedStrategy struct {
 
 // Strategies returns all strategies defined in the current module and
 // its imports.
-func (c *Client) Strategies() []NamedStrategy {
-	if !c.active {
-		return nil
-	}
+func (c *Client) Strategies(ctx context.Context) ([]NamedStrategy, error) {
+	results, err := c.metaLevel(ctx, func(module string) []string {
+		return []string{"getStrats(upModule(" + module + ", true))"}
+	})
 
-	c.stdin.Write([]byte("show strats .\n"))
+	if err != nil {
+		return nil, err
+	}
 
 	var strats = make([]NamedStrategy, 0)
 
-	for !c.promptReached() {
-		str, _ := c.stdout.ReadString('\n')
-
-		if match := stratRegex.FindStringSubmatch(str); match != nil {
-			var params = make([]string, 0)
-
-			if len(match[2]) > 0 {
-				params = strings.Split(match[2], " ")
-			}
-
-			strats = append(strats, NamedStrategy{match[1], params, match[3]})
-		}
+	for _, decl := range term.StratDecls(results[0]) {
+		strats = append(strats, NamedStrategy{decl.Name, decl.Arity, decl.Subject})
 	}
 
-	c.advanceUntilPrompt()
-
-	return strats
+	return strats, nil
 }
 
 // MaudeOperator describes the signature of an operator.
@@ -206,96 +260,83 @@ type MaudeOperator struct {
 
 // AtomicProps returns all atomic propositions (i.e. all operator whose range
 // sort is Prop or a subsort) defined in the current module and its imports.
-func (c *Client) AtomicProps() []MaudeOperator {
-	if !c.active {
-		return nil
-	}
-
+func (c *Client) AtomicProps(ctx context.Context) ([]MaudeOperator, error) {
 	// The user might have defined atomic propositions in a custom subsort
 	// of Prop. Hence, propSorts will act as the set of all Prop sorts and
 	// all operator with a range in the set will be collected.
+	c, release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
 	var propSorts = make(map[string]struct{})
 	propSorts["Prop"] = struct{}{}
 
-	subsorts, _ := c.Subsorts("Prop")
+	subsorts, _, err := c.Subsorts(ctx, "Prop")
 
 	// Prop is not defined, we signal it by returning nil
 	if subsorts == nil {
-		return nil
+		return nil, err
 	}
 
 	for _, value := range subsorts {
 		propSorts[value] = struct{}{}
 	}
 
-	c.stdin.Write([]byte("show op .\n"))
-
-	var props = make([]MaudeOperator, 0)
-
-	for !c.promptReached() {
-		str, _ := c.stdout.ReadString('\n')
-
-		if match := propRegex.FindStringSubmatch(str); match != nil {
+	results, err := c.metaLevel(ctx, func(module string) []string {
+		return []string{"getOps(upModule(" + module + ", true))"}
+	})
 
-			// If the range sort is that of an atomic proposition
-			if _, isProp := propSorts[match[3]]; isProp {
-				var params []string
+	if err != nil {
+		return nil, err
+	}
 
-				if match[2] == "" {
-					params = make([]string, 0)
-				} else {
-					params = strings.Split(strings.TrimRight(match[2], " "), " ")
-				}
+	var props = make([]MaudeOperator, 0)
 
-				props = append(props, MaudeOperator{match[1], params, "Prop"})
-			}
+	for _, decl := range term.OpDecls(results[0]) {
+		// If the range sort is that of an atomic proposition
+		if _, isProp := propSorts[decl.Coarity]; isProp {
+			props = append(props, MaudeOperator{decl.Name, decl.Arity, decl.Coarity})
 		}
 	}
 
-	c.advanceUntilPrompt()
-
-	return props
+	return props, nil
 }
 
 // collectStatements collects all statements in the current module starting with the
-// given keyword (an its conditional version). If the argument is a non-empty string,
-// only statements with that label will be listed.
-func (c *Client) collectStatements(statementType, keyword, label string) []string {
-	if !c.active {
-		return nil
+// given keyword (an its conditional version) that are accepted by the given function.
+func (c *Client) collectStatements(ctx context.Context, statementType, keyword string, accept func(string) bool) ([]string, error) {
+	lines, err := c.exchange(ctx, "show "+statementType+" .\n")
+	if err != nil {
+		return nil, err
 	}
 
-	c.stdin.Write([]byte("show " + statementType + " ."))
-
 	var statements = make([]string, 0)
-	// Label detection may not be accurate
-	var labelAttribute = "label " + label
 	// Conditional keyword are prefixed by a c
 	keyword = keyword + " "
 	var conditionalKeyword = "c" + keyword
 	// A statement may continue in multiple lines we have to collect
 	var statement = ""
 
-	// Function to append statements after checking they have the right label
+	// Function to append statements after checking they are accepted
 	var appendStatement = func (statement string) {
-		statement = strings.TrimSuffix(statement, "\n")
-		if label == "" || strings.Contains(statement, labelAttribute) {
+		if accept(statement) {
 			statements = append(statements, statement)
 		}
 	}
 
-	for !c.promptReached() {
-		line, _ := c.stdout.ReadString('\n')
-
+	for _, line := range lines {
 		if strings.HasPrefix(line, conditionalKeyword) || strings.HasPrefix(line, keyword) {
 			// A new rule starts, the old one has to be saved
 			if statement != "" {
 				appendStatement(statement)
-				statement = line
 			}
+			statement = line
 		} else {
 			// Another line for the same statement
-			statement = statement + line
+			statement = statement + "\n" + line
 		}
 	}
 
@@ -303,33 +344,111 @@ func (c *Client) collectStatements(statementType, keyword, label string) []strin
 		appendStatement(statement)
 	}
 
-	c.advanceUntilPrompt()
+	return statements, nil
+}
+
+// withLabel returns a function that accepts the statements with the given
+// label, or all of them if it is empty. Labels are written either before the
+// statement like rl [label] : ... or as an attribute like [label label].
+func withLabel(label string) func(string) bool {
+	if label == "" {
+		return func(string) bool { return true }
+	}
+
+	var prefix = "[" + label + "] :"
+
+	return func(statement string) bool {
+		// Skips the keyword
+		var body = statement[strings.IndexByte(statement, ' ')+1:]
 
-	return statements
+		if strings.HasPrefix(body, prefix) {
+			return true
+		}
+
+		// Label detection may not be accurate
+		var attribute = strings.Index(statement, "label "+label)
+		var end = attribute + len("label "+label)
+
+		return attribute >= 0 && (end == len(statement) || strings.IndexByte(" ]\n", statement[end]) >= 0)
+	}
 }
 
 // Rules returns all rule statements in the current module. If the argument is
 // a non-empty string, only rules with that label will be listed.
-func (c *Client) Rules(label string) []string {
-	return c.collectStatements("rules", "rl", label)
+func (c *Client) Rules(ctx context.Context, label string) ([]string, error) {
+	return c.collectStatements(ctx, "rules", "rl", withLabel(label))
 }
 
 // Equations returns all equation statements in the current module. If the
 // argument is a non-empty string, only equations with that label will be listed.
-func (c *Client) Equations(label string) []string {
-	return c.collectStatements("eqs", "eq", label)
+func (c *Client) Equations(ctx context.Context, label string) ([]string, error) {
+	return c.collectStatements(ctx, "eqs", "eq", withLabel(label))
 }
 
 // Memberships returns all membership axiom statements in the
 // current module. If the argument is a non-empty string, only axioms
 // with that label will be listed.
-func (c *Client) Memberships(label string) []string {
-	return c.collectStatements("mbs", "mb", label)
+func (c *Client) Memberships(ctx context.Context, label string) ([]string, error) {
+	return c.collectStatements(ctx, "mbs", "mb", withLabel(label))
 }
 
 // StrategyDefinitions returns all strategy definition statements in the
 // current module. If the argument is a non-empty string, only definitions
 // with that label will be listed.
-func (c *Client) StrategyDefinitions(label string) []string {
-	return c.collectStatements("sds", "sd", label)
+func (c *Client) StrategyDefinitions(ctx context.Context, label string) ([]string, error) {
+	return c.collectStatements(ctx, "sds", "sd", withLabel(label))
+}
+
+// DefinitionsOf returns the definitions of the strategy with the given name
+// in the current module.
+func (c *Client) DefinitionsOf(ctx context.Context, strategy string) ([]string, error) {
+	return c.collectStatements(ctx, "sds", "sd", func(statement string) bool {
+		// Skips the keyword and the label, if any
+		var body = statement[strings.IndexByte(statement, ' ')+1:]
+
+		if strings.HasPrefix(body, "[") {
+			if colon := strings.Index(body, "] : "); colon >= 0 {
+				body = body[colon+4:]
+			}
+		}
+
+		// The name is followed by the arguments or by :=
+		var end = strings.IndexAny(body, "( ")
+
+		return end >= 0 && body[:end] == strategy
+	})
+}
+
+// TransitionSource returns the statements behind a transition label in the
+// given module, which are the rules with that label or, for the transitions
+// of opaque strategies, the definitions of the strategy with that name.
+func (c *Client) TransitionSource(ctx context.Context, module, label string, opaque bool) ([]string, error) {
+	if label == "" {
+		return make([]string, 0), nil
+	}
+
+	c, release, err := c.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer release()
+
+	// The current module is selected again afterwards
+	current, err := c.CurrentModuleName(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	defer c.Select(ctx, current)
+
+	if err := c.Select(ctx, module); err != nil {
+		return nil, err
+	}
+
+	if opaque {
+		return c.DefinitionsOf(ctx, label)
+	}
+
+	return c.Rules(ctx, label)
 }
@@ -0,0 +1,297 @@
+package term
+
+import (
+	"strings"
+)
+
+// Param is a parameter of a parameterized module.
+type Param struct {
+	Name   string
+	Theory string
+}
+
+// Import is an importation of another module.
+type Import struct {
+	// Mode is protecting, extending, including or generated-by
+	Mode   string
+	Module string
+}
+
+// Subsort is a subsort declaration.
+type Subsort struct {
+	Lower string
+	Upper string
+}
+
+// Op is an operator declaration.
+type Op struct {
+	Name    string
+	Arity   []string
+	Coarity string
+	// Attrs are the attributes in prefix form, like assoc or prec(33)
+	Attrs   []string
+}
+
+// Strat is a strategy declaration.
+type Strat struct {
+	Name    string
+	Arity   []string
+	Subject string
+}
+
+// Module is the signature of a metarepresented module or theory. Its
+// statements (equations, rules...) are not kept.
+type Module struct {
+	// Type is fmod, mod, smod, fth, th or sth
+	Type     string
+	Name     string
+	Params   []Param
+	Imports  []Import
+	Sorts    []string
+	Subsorts []Subsort
+	Ops      []Op
+	Strats   []Strat
+}
+
+// View is a view from a theory to a module. Its mappings are not kept.
+type View struct {
+	Name string
+	From string
+	To   string
+}
+
+// ModuleFromNode converts a metarepresented module in prefix form, like
+// fmod_is_sorts_.________endfm('M, nil, 'S, none, ...), to a module.
+func ModuleFromNode(node *Node) (*Module, error) {
+	// The constructor name starts with the module type and its arguments
+	// are (in this order) the header, imports, sorts, subsorts, operators,
+	// memberships, equations, rules, strategies and strategy definitions
+	var module = &Module{Type: node.Symbol[:strings.IndexByte(node.Symbol+"_", '_')]}
+	var minArgs int
+
+	switch module.Type {
+		case "fmod", "fth" : minArgs = 7
+		case "mod", "th"   : minArgs = 8
+		case "smod", "sth" : minArgs = 10
+		default            : return nil, ErrSyntax
+	}
+
+	if len(node.Args) < minArgs {
+		return nil, ErrSyntax
+	}
+
+	// Parameterized modules have a header like _{_}('M, _::_('X, 'TRIV))
+	var header = node.Args[0]
+
+	module.Params = make([]Param, 0)
+
+	if header.Is("_{_}", 2) {
+		for _, param := range header.Args[1].List("_,_") {
+			if param.Is("_::_", 2) {
+				module.Params = append(module.Params, Param{
+					QidName(param.Args[0].Symbol),
+					moduleExpression(param.Args[1]),
+				})
+			}
+		}
+
+		header = header.Args[0]
+	}
+
+	module.Name = QidName(header.Symbol)
+	module.Imports = make([]Import, 0)
+
+	for _, imp := range node.Args[1].List("__") {
+		if len(imp.Args) == 1 {
+			module.Imports = append(module.Imports, Import{
+				strings.TrimSuffix(Unescape(imp.Symbol), "_."),
+				moduleExpression(imp.Args[0]),
+			})
+		}
+	}
+
+	module.Sorts = SortSet(node.Args[2])
+	module.Subsorts = SubsortDecls(node.Args[3])
+	module.Ops = OpDecls(node.Args[4])
+	module.Strats = make([]Strat, 0)
+
+	if minArgs == 10 {
+		module.Strats = StratDecls(node.Args[8])
+	}
+
+	return module, nil
+}
+
+// ViewFromNode converts a metarepresented view in prefix form, like
+// view_from_to_is___endv('Nat, 'TRIV, 'NAT, ...), to a view.
+func ViewFromNode(node *Node) (*View, error) {
+	if !strings.HasPrefix(Unescape(node.Symbol), "view_from_to_is_") || len(node.Args) < 3 {
+		return nil, ErrSyntax
+	}
+
+	return &View{
+		moduleExpression(node.Args[0]),
+		moduleExpression(node.Args[1]),
+		moduleExpression(node.Args[2]),
+	}, nil
+}
+
+// moduleExpression prints a metarepresented module expression (or view
+// header).
+func moduleExpression(node *Node) string {
+	if node.Is("_{_}", 2) {
+		var args = node.Args[1].List("_,_")
+		var names = make([]string, len(args))
+
+		for i, arg := range args {
+			// View headers have parameters like _::_('X, 'TRIV)
+			if arg.Is("_::_", 2) {
+				names[i] = QidName(arg.Args[0].Symbol) + " :: " + moduleExpression(arg.Args[1])
+			} else {
+				names[i] = moduleExpression(arg)
+			}
+		}
+
+		return moduleExpression(node.Args[0]) + "{" + strings.Join(names, ", ") + "}"
+	}
+
+	if len(node.Args) == 0 {
+		return QidName(node.Symbol)
+	}
+
+	return Unescape(node.String())
+}
+
+// SortSet converts a set of sorts like _;_('Bool, 'Nat) to a list.
+func SortSet(node *Node) []string {
+	return QidNames(node.List("_;_"))
+}
+
+// SubsortDecls converts a set of subsort declarations to a list.
+func SubsortDecls(node *Node) []Subsort {
+	var subsorts = make([]Subsort, 0)
+
+	for _, decl := range node.List("__") {
+		if decl.Is("subsort_<_.", 2) {
+			subsorts = append(subsorts, Subsort{
+				QidName(decl.Args[0].Symbol),
+				QidName(decl.Args[1].Symbol),
+			})
+		}
+	}
+
+	return subsorts
+}
+
+// OpDecls converts a set of operator declarations to a list.
+func OpDecls(node *Node) []Op {
+	var ops = make([]Op, 0)
+
+	// Operator declarations are op_:_->_[_].('f, __('S1, 'S2), 'S, attrs)
+	for _, decl := range node.List("__") {
+		if !decl.Is("op_:_->_[_].", 4) {
+			continue
+		}
+
+		var attrs = decl.Args[3].List("__")
+		var op = Op{
+			QidName(decl.Args[0].Symbol),
+			QidNames(decl.Args[1].List("__")),
+			QidName(decl.Args[2].Symbol),
+			make([]string, len(attrs)),
+		}
+
+		for i, attr := range attrs {
+			op.Attrs[i] = Unescape(attr.String())
+		}
+
+		ops = append(ops, op)
+	}
+
+	return ops
+}
+
+// StratDecls converts a set of strategy declarations to a list.
+func StratDecls(node *Node) []Strat {
+	var strats = make([]Strat, 0)
+
+	// Strategy declarations are strat_:_@_[_].('s, __('S1, 'S2), 'S, attrs)
+	for _, decl := range node.List("__") {
+		if decl.Is("strat_:_@_[_].", 4) {
+			strats = append(strats, Strat{
+				QidName(decl.Args[0].Symbol),
+				QidNames(decl.Args[1].List("__")),
+				QidName(decl.Args[2].Symbol),
+			})
+		}
+	}
+
+	return strats
+}
+
+// String prints the signature of the module in Maude syntax.
+func (m *Module) String() string {
+	var builder strings.Builder
+
+	builder.WriteString(m.Type + " " + m.Name)
+
+	if len(m.Params) > 0 {
+		var params = make([]string, len(m.Params))
+
+		for i, param := range m.Params {
+			params[i] = param.Name + " :: " + param.Theory
+		}
+
+		builder.WriteString("{" + strings.Join(params, ", ") + "}")
+	}
+
+	builder.WriteString(" is\n")
+
+	for _, imp := range m.Imports {
+		builder.WriteString("\t" + imp.Mode + " " + imp.Module + " .\n")
+	}
+
+	if len(m.Sorts) > 0 {
+		builder.WriteString("\tsorts " + strings.Join(m.Sorts, " ") + " .\n")
+	}
+
+	for _, subsort := range m.Subsorts {
+		builder.WriteString("\tsubsort " + subsort.Lower + " < " + subsort.Upper + " .\n")
+	}
+
+	for _, op := range m.Ops {
+		builder.WriteString("\top " + op.Name + " : ")
+
+		if len(op.Arity) > 0 {
+			builder.WriteString(strings.Join(op.Arity, " ") + " ")
+		}
+
+		builder.WriteString("-> " + op.Coarity)
+
+		if len(op.Attrs) > 0 {
+			builder.WriteString(" [" + strings.Join(op.Attrs, " ") + "]")
+		}
+
+		builder.WriteString(" .\n")
+	}
+
+	for _, strat := range m.Strats {
+		builder.WriteString("\tstrat " + strat.Name + " ")
+
+		if len(strat.Arity) > 0 {
+			builder.WriteString(": " + strings.Join(strat.Arity, " ") + " ")
+		}
+
+		builder.WriteString("@ " + strat.Subject + " .\n")
+	}
+
+	// The closing keyword is end followed by the abbreviated module type
+	var closing = map[string]string{
+		"fmod": "endfm", "mod": "endm", "smod": "endsm",
+		"fth": "endfth", "th": "endth", "sth": "endsth",
+	}
+
+	builder.WriteString(closing[m.Type])
+
+	return builder.String()
+}
@@ -0,0 +1,178 @@
+package term
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestModuleFromNode(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected *Module
+	}{
+		{`fmod_is_sorts_.____endfm('LIST, __(protecting_.('NAT), including_.('BOOL)), _;_('List, 'NeList),
+	subsort_<_.('NeList, 'List), __(op_:_->_[_].('nil, nil, 'List, ctor),
+	op_:_->_[_].('__, __('List, 'List), 'List, __(assoc, ctor, id('nil.List)))), none, none)`,
+			&Module{
+				Type:     "fmod",
+				Name:     "LIST",
+				Params:   []Param{},
+				Imports:  []Import{{"protecting", "NAT"}, {"including", "BOOL"}},
+				Sorts:    []string{"List", "NeList"},
+				Subsorts: []Subsort{{"NeList", "List"}},
+				Ops: []Op{
+					{"nil", []string{}, "List", []string{"ctor"}},
+					{"__", []string{"List", "List"}, "List", []string{"assoc", "ctor", "id('nil.List)"}},
+				},
+				Strats: []Strat{},
+			}},
+		// Empty sets with sort annotations and instances of modules
+		{"mod_is_sorts_._____endm('COUNTER, __(protecting_.(_{_}('LIST, 'Nat)),\n" +
+			"\textending_.(_{_}('MAP, _`,_('Nat, 'String)))), 'Counter, (none).SubsortDeclSet,\n" +
+			"\top_:_->_[_].('`[_`], 'Nat, 'Counter, (none).AttrSet), none, none, none)",
+			&Module{
+				Type:     "mod",
+				Name:     "COUNTER",
+				Params:   []Param{},
+				Imports:  []Import{{"protecting", "LIST{Nat}"}, {"extending", "MAP{Nat, String}"}},
+				Sorts:    []string{"Counter"},
+				Subsorts: []Subsort{},
+				Ops:      []Op{{"[_]", []string{"Nat"}, "Counter", []string{}}},
+				Strats:   []Strat{},
+			}},
+		// Parameterized strategy modules
+		{"smod_is_sorts_._____________endsm(_{_}('STACK, _`,_(_::_('X, 'TRIV), _::_('Y, 'TRIV))),\n" +
+			"\t(nil).ImportList, (none).SortSet, (none).SubsortDeclSet, (none).OpDeclSet, none, none, none,\n" +
+			"\t__(strat_:_@_[_].('push, 'Nat, 'Stack, none), strat_:_@_[_].('pop, nil, 'Stack, none)), none)",
+			&Module{
+				Type:     "smod",
+				Name:     "STACK",
+				Params:   []Param{{"X", "TRIV"}, {"Y", "TRIV"}},
+				Imports:  []Import{},
+				Sorts:    []string{},
+				Subsorts: []Subsort{},
+				Ops:      []Op{},
+				Strats:   []Strat{{"push", []string{"Nat"}, "Stack"}, {"pop", []string{}, "Stack"}},
+			}},
+		{`fth_is_sorts_.____endfth('TRIV, nil, 'Elt, none, none, none, none)`,
+			&Module{
+				Type:     "fth",
+				Name:     "TRIV",
+				Params:   []Param{},
+				Imports:  []Import{},
+				Sorts:    []string{"Elt"},
+				Subsorts: []Subsort{},
+				Ops:      []Op{},
+				Strats:   []Strat{},
+			}},
+	}
+
+	for _, test := range tests {
+		parsed, err := ParsePrefix(test.input)
+		if err != nil {
+			t.Errorf("ParsePrefix(%q) fails with %v", test.input, err)
+			continue
+		}
+
+		if result, err := ModuleFromNode(parsed); err != nil {
+			t.Errorf("ModuleFromNode(%q) fails with %v", test.input, err)
+		} else if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("ModuleFromNode(%q) = %+v, expected %+v", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestModuleFromNodeErrors(t *testing.T) {
+	var inputs = []string{
+		// Not a module
+		"noParse(5)",
+		"'NAT",
+		"view_from_to_is___endv('Nat, 'TRIV, 'NAT, none, none)",
+		// Missing arguments
+		"fmod_is_sorts_.____endfm('M, nil, none, none, none, none)",
+		"smod_is_sorts_._____________endsm('M, nil, none, none, none, none, none, none)",
+	}
+
+	for _, input := range inputs {
+		parsed, err := ParsePrefix(input)
+		if err != nil {
+			t.Errorf("ParsePrefix(%q) fails with %v", input, err)
+			continue
+		}
+
+		if result, err := ModuleFromNode(parsed); !errors.Is(err, ErrSyntax) {
+			t.Errorf("ModuleFromNode(%q) = %+v, %v, expected a syntax error", input, result, err)
+		}
+	}
+}
+
+func TestModuleString(t *testing.T) {
+	var module = &Module{
+		Type:     "smod",
+		Name:     "STACK",
+		Params:   []Param{{"X", "TRIV"}},
+		Imports:  []Import{{"protecting", "LIST{X}"}},
+		Sorts:    []string{"Stack", "NeStack"},
+		Subsorts: []Subsort{{"NeStack", "Stack"}},
+		Ops: []Op{
+			{"empty", []string{}, "Stack", []string{"ctor"}},
+			{"push", []string{"X$Elt", "Stack"}, "NeStack", []string{"ctor", "prec(33)"}},
+		},
+		Strats: []Strat{{"pop", []string{}, "Stack"}, {"pushAll", []string{"Nat"}, "Stack"}},
+	}
+
+	const expected = `smod STACK{X :: TRIV} is
+	protecting LIST{X} .
+	sorts Stack NeStack .
+	subsort NeStack < Stack .
+	op empty : -> Stack [ctor] .
+	op push : X$Elt Stack -> NeStack [ctor prec(33)] .
+	strat pop @ Stack .
+	strat pushAll : Nat @ Stack .
+endsm`
+
+	if printed := module.String(); printed != expected {
+		t.Errorf("the module is printed as\n%s\nexpected\n%s", printed, expected)
+	}
+}
+
+func TestViewFromNode(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected *View
+	}{
+		{"view_from_to_is___endv('Nat, 'TRIV, 'NAT, sort_to_.('Elt, 'Nat), none)",
+			&View{"Nat", "TRIV", "NAT"}},
+		// Views with strategy mappings have more arguments
+		{"view_from_to_is____endv('Walk, 'STRAT, 'GRAPH, none, none, none)",
+			&View{"Walk", "STRAT", "GRAPH"}},
+		{"view_from_to_is___endv(_{_}('List, _::_('X, 'TRIV)), 'TRIV, _{_}('LIST, 'X), none, none)",
+			&View{"List{X :: TRIV}", "TRIV", "LIST{X}"}},
+		{"view_from_to_is___endv('`{Set`}, 'TRIV, _{_}('SET, _`,_('Nat, 'Bool)), none, none)",
+			&View{"{Set}", "TRIV", "SET{Nat, Bool}"}},
+	}
+
+	for _, test := range tests {
+		parsed, err := ParsePrefix(test.input)
+		if err != nil {
+			t.Errorf("ParsePrefix(%q) fails with %v", test.input, err)
+			continue
+		}
+
+		if result, err := ViewFromNode(parsed); err != nil {
+			t.Errorf("ViewFromNode(%q) fails with %v", test.input, err)
+		} else if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("ViewFromNode(%q) = %+v, expected %+v", test.input, result, test.expected)
+		}
+	}
+
+	for _, input := range []string{"noParse(5)", "view_from_to_is___endv('Nat, 'TRIV)", "fmod_is_sorts_.____endfm('M, nil, none, none, none, none, none)"} {
+		parsed, err := ParsePrefix(input)
+		if err != nil {
+			t.Errorf("ParsePrefix(%q) fails with %v", input, err)
+		} else if result, err := ViewFromNode(parsed); !errors.Is(err, ErrSyntax) {
+			t.Errorf("ViewFromNode(%q) = %+v, %v, expected a syntax error", input, result, err)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Package term parses the terms and module metarepresentations printed by
+// Maude into Go values, and prints them back.
+package term
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrSyntax is returned when the input of a parser is not well-formed.
+var ErrSyntax = errors.New("term: syntax error")
+
+// Node is a term in prefix form, as printed by Maude when mixfix printing
+// is disabled. Symbols are kept as printed, with their escaping backquotes.
+type Node struct {
+	Symbol string
+	Args   []*Node
+}
+
+// reader is the common scanner of the term parsers.
+type reader struct {
+	input string
+	pos   int
+}
+
+// syntaxError describes the position of a syntax error.
+type syntaxError struct {
+	pos int
+}
+
+func (e *syntaxError) Error() string {
+	return "term: syntax error at position " + strconv.Itoa(e.pos)
+}
+
+func (e *syntaxError) Unwrap() error {
+	return ErrSyntax
+}
+
+// skipSpaces skips the blank characters (including line breaks).
+func (r *reader) skipSpaces() {
+	for r.pos < len(r.input) && strings.IndexByte(" \t\r\n", r.input[r.pos]) >= 0 {
+		r.pos++
+	}
+}
+
+// peek returns the next character (or zero at the end of the input).
+func (r *reader) peek() byte {
+	if r.pos < len(r.input) {
+		return r.input[r.pos]
+	}
+
+	return 0
+}
+
+// token reads a symbol until a blank or one of the given delimiters.
+// Backquotes escape the following character and string literals are
+// read whole.
+func (r *reader) token(delimiters string) string {
+	var start = r.pos
+
+	loop: for r.pos < len(r.input) {
+		var char = r.input[r.pos]
+
+		switch {
+			case strings.IndexByte(" \t\r\n", char) >= 0 || strings.IndexByte(delimiters, char) >= 0 :
+				break loop
+			case char == '`' :
+				r.pos += 2
+			case char == '"' :
+				r.pos++
+				for r.pos < len(r.input) && r.input[r.pos] != '"' {
+					if r.input[r.pos] == '\\' {
+						r.pos++
+					}
+					r.pos++
+				}
+				r.pos++
+			default :
+				r.pos++
+		}
+	}
+
+	// Unterminated string literals and escapes are not valid tokens
+	if r.pos > len(r.input) {
+		r.pos = len(r.input)
+		return ""
+	}
+
+	return r.input[start:r.pos]
+}
+
+// ParsePrefix parses a term in prefix form.
+func ParsePrefix(input string) (*Node, error) {
+	var r = reader{input: input}
+
+	node, err := r.node()
+	if err != nil {
+		return nil, err
+	}
+
+	r.skipSpaces()
+
+	if r.pos != len(input) {
+		return nil, &syntaxError{r.pos}
+	}
+
+	return node, nil
+}
+
+// node reads a term in prefix form.
+func (r *reader) node() (*Node, error) {
+	r.skipSpaces()
+
+	// Parenthesized terms, possibly with a sort annotation like (none).SortSet
+	if r.peek() == '(' {
+		r.pos++
+
+		node, err := r.node()
+		if err != nil {
+			return nil, err
+		}
+
+		r.skipSpaces()
+
+		if r.peek() != ')' {
+			return nil, &syntaxError{r.pos}
+		}
+
+		r.pos++
+
+		if r.peek() == '.' {
+			r.token("(),")
+		}
+
+		return node, nil
+	}
+
+	var node = &Node{Symbol: r.token("(),")}
+
+	if node.Symbol == "" {
+		return nil, &syntaxError{r.pos}
+	}
+
+	if r.peek() != '(' {
+		return node, nil
+	}
+
+	r.pos++
+
+	for {
+		arg, err := r.node()
+		if err != nil {
+			return nil, err
+		}
+
+		node.Args = append(node.Args, arg)
+		r.skipSpaces()
+
+		switch r.peek() {
+			case ',' : r.pos++
+			case ')' : r.pos++; return node, nil
+			default  : return nil, &syntaxError{r.pos}
+		}
+	}
+}
+
+// Is tells whether the node is an application of the given operator (whose
+// name is compared without escaping backquotes) with the given arity.
+func (n *Node) Is(symbol string, arity int) bool {
+	return len(n.Args) == arity && Unescape(n.Symbol) == symbol
+}
+
+// List flattens a term built with an associative constructor into the list
+// of its elements. The empty set and list constants are empty lists.
+func (n *Node) List(constructor string) []*Node {
+	if Unescape(n.Symbol) == constructor {
+		var elems = make([]*Node, 0, len(n.Args))
+
+		for _, arg := range n.Args {
+			elems = append(elems, arg.List(constructor)...)
+		}
+
+		return elems
+	}
+
+	if len(n.Args) == 0 && isEmpty(n.Symbol) {
+		return make([]*Node, 0)
+	}
+
+	return []*Node{n}
+}
+
+// String prints the node back in prefix form.
+func (n *Node) String() string {
+	var builder strings.Builder
+	n.write(&builder)
+	return builder.String()
+}
+
+func (n *Node) write(builder *strings.Builder) {
+	builder.WriteString(n.Symbol)
+
+	if len(n.Args) > 0 {
+		builder.WriteByte('(')
+
+		for i, arg := range n.Args {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			arg.write(builder)
+		}
+
+		builder.WriteByte(')')
+	}
+}
+
+// isEmpty tells whether a constant is the empty set or list.
+func isEmpty(symbol string) bool {
+	return symbol == "none" || symbol == "nil" || symbol == "empty"
+}
+
+// Unescape removes the backquotes that escape special characters.
+func Unescape(symbol string) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(symbol); i++ {
+		if symbol[i] == '`' && i+1 < len(symbol) {
+			i++
+		}
+		builder.WriteByte(symbol[i])
+	}
+
+	return builder.String()
+}
+
+// Escape escapes the special characters of a symbol with backquotes.
+func Escape(symbol string) string {
+	var builder strings.Builder
+
+	for i := 0; i < len(symbol); i++ {
+		if strings.IndexByte("()[]{},", symbol[i]) >= 0 {
+			builder.WriteByte('`')
+		}
+		builder.WriteByte(symbol[i])
+	}
+
+	return builder.String()
+}
+
+// QidName returns the name quoted by a Qid constant.
+func QidName(qid string) string {
+	return Unescape(strings.TrimPrefix(qid, "'"))
+}
+
+// Qid returns the Qid constant that quotes a name.
+func Qid(name string) string {
+	return "'" + Escape(name)
+}
+
+// QidNames converts a list of Qid constants to the names they quote.
+func QidNames(nodes []*Node) []string {
+	var names = make([]string, len(nodes))
+
+	for i, node := range nodes {
+		names[i] = QidName(node.Symbol)
+	}
+
+	return names
+}
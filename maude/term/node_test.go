@@ -0,0 +1,179 @@
+package term
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// node builds a node for the tests
+func node(symbol string, args ...*Node) *Node {
+	return &Node{symbol, args}
+}
+
+func TestParsePrefix(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected *Node
+	}{
+		{"'a", node("'a")},
+		{"noParse(5)", node("noParse", node("5"))},
+		{"_[_]('_+_, _`,_('X:Nat, '0.Nat))",
+			node("_[_]", node("'_+_"), node("_`,_", node("'X:Nat"), node("'0.Nat")))},
+		// Empty constants with sort annotations
+		{"(none).SortSet", node("none")},
+		{"__((nil).ImportList, ((none).SortSet))",
+			node("__", node("nil"), node("none"))},
+		// Escaped symbols
+		{"'_`,_", node("'_`,_")},
+		{"_[_]('`{_`}, 'X:`[Nat`])", node("_[_]", node("'`{_`}"), node("'X:`[Nat`]"))},
+		{"'`(x`,y`).Pair", node("'`(x`,y`).Pair")},
+		// String literals with special characters and quotes
+		{"'\"f(a, b)\".String", node("'\"f(a, b)\".String")},
+		{"g('\"say \\\"hi\\\", (x)\".String)", node("g", node("'\"say \\\"hi\\\", (x)\".String"))},
+		// Floats
+		{"'1.5e-1.Float", node("'1.5e-1.Float")},
+		{"_[_]('-_, '-2.0e+10.FiniteFloat)", node("_[_]", node("'-_"), node("'-2.0e+10.FiniteFloat"))},
+		// Blanks and line breaks
+		{"  f(\n\ta ,\r\n\tb)  ", node("f", node("a"), node("b"))},
+	}
+
+	for _, test := range tests {
+		result, err := ParsePrefix(test.input)
+
+		if err != nil {
+			t.Errorf("ParsePrefix(%q) fails with %v", test.input, err)
+		} else if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("ParsePrefix(%q) = %s, expected %s", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestParsePrefixErrors(t *testing.T) {
+	var inputs = []string{
+		"", "  ", "f(", "f(a", "f(a,)", "f()", "f(a))", "f(a b)", "a b",
+		")", "(a", "(a b)", ",", "f(a,,b)", "'\"unterminated",
+	}
+
+	for _, input := range inputs {
+		if result, err := ParsePrefix(input); err == nil {
+			t.Errorf("ParsePrefix(%q) = %s, expected a syntax error", input, result)
+		} else if !errors.Is(err, ErrSyntax) {
+			t.Errorf("ParsePrefix(%q) fails with %v, expected a syntax error", input, err)
+		}
+	}
+}
+
+func TestNodeString(t *testing.T) {
+	var inputs = []string{
+		"'a",
+		"noParse(5)",
+		"_[_]('_+_, _`,_('X:Nat, '0.Nat))",
+		"_[_]('`{_`}, 'X:`[Nat`])",
+		"g('\"say \\\"hi\\\", (x)\".String)",
+	}
+
+	for _, input := range inputs {
+		if result, err := ParsePrefix(input); err != nil {
+			t.Errorf("ParsePrefix(%q) fails with %v", input, err)
+		} else if printed := result.String(); printed != input {
+			t.Errorf("ParsePrefix(%q) is printed as %q", input, printed)
+		}
+	}
+}
+
+func TestNodeList(t *testing.T) {
+	var tests = []struct {
+		input       string
+		constructor string
+		expected    []string
+	}{
+		{"_;_('Bool, _;_('Nat, 'Int))", "_;_", []string{"'Bool", "'Nat", "'Int"}},
+		{"'Nat", "__", []string{"'Nat"}},
+		{"nil", "__", []string{}},
+		{"(none).SortSet", "_;_", []string{}},
+		{"empty", "_,_", []string{}},
+		{"_`,_('a, 'b)", "_,_", []string{"'a", "'b"}},
+	}
+
+	for _, test := range tests {
+		parsed, err := ParsePrefix(test.input)
+		if err != nil {
+			t.Errorf("ParsePrefix(%q) fails with %v", test.input, err)
+			continue
+		}
+
+		var list = parsed.List(test.constructor)
+		var symbols = make([]string, len(list))
+
+		for i, elem := range list {
+			symbols[i] = elem.String()
+		}
+
+		if !reflect.DeepEqual(symbols, test.expected) {
+			t.Errorf("%q as a list of %s is %v, expected %v", test.input, test.constructor, symbols, test.expected)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	var tests = []struct {
+		symbol  string
+		escaped string
+	}{
+		{"abc", "abc"},
+		{"_+_", "_+_"},
+		{"_,_", "_`,_"},
+		{"{_}", "`{_`}"},
+		{"[Nat]", "`[Nat`]"},
+		{"f(x, y)", "f`(x`, y`)"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if escaped := Escape(test.symbol); escaped != test.escaped {
+			t.Errorf("Escape(%q) = %q, expected %q", test.symbol, escaped, test.escaped)
+		}
+
+		if symbol := Unescape(test.escaped); symbol != test.symbol {
+			t.Errorf("Unescape(%q) = %q, expected %q", test.escaped, symbol, test.symbol)
+		}
+	}
+
+	// Unescaping is more liberal than escaping
+	var unescapes = []struct {
+		escaped string
+		symbol  string
+	}{
+		{"`a`b", "ab"},
+		{"``", "`"},
+		{"a`", "a`"},
+	}
+
+	for _, test := range unescapes {
+		if symbol := Unescape(test.escaped); symbol != test.symbol {
+			t.Errorf("Unescape(%q) = %q, expected %q", test.escaped, symbol, test.symbol)
+		}
+	}
+}
+
+func TestQid(t *testing.T) {
+	var tests = []struct {
+		name string
+		qid  string
+	}{
+		{"NAT", "'NAT"},
+		{"_,_", "'_`,_"},
+		{"LIST{Nat}", "'LIST`{Nat`}"},
+	}
+
+	for _, test := range tests {
+		if qid := Qid(test.name); qid != test.qid {
+			t.Errorf("Qid(%q) = %q, expected %q", test.name, qid, test.qid)
+		}
+
+		if name := QidName(test.qid); name != test.name {
+			t.Errorf("QidName(%q) = %q, expected %q", test.qid, name, test.name)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package term
+
+import (
+	"strings"
+)
+
+// Kind distinguishes the different forms of metarepresented terms.
+type Kind int
+
+const (
+	// Constant like '0.Nat (the sort is empty for plain Qids like 'a)
+	Constant Kind = iota
+	// Variable like 'X:Nat
+	Variable
+	// Application of an operator to some arguments like '_+_['X:Nat, '0.Nat]
+	Application
+	// Empty is one of the empty constants empty, none or nil
+	Empty
+)
+
+// Term is a metarepresented term, as those of sort Term in META-LEVEL.
+type Term struct {
+	Kind Kind
+	// Name of the operator, constant or variable (without quote or escapes)
+	Name string
+	// Sort of constants and variables (without escapes)
+	Sort string
+	// Args are the arguments of applications
+	Args []*Term
+}
+
+// Parse parses a metarepresented term as printed by Maude in mixfix form,
+// like '_+_['X:Nat, 's_['0.Zero]].
+func Parse(input string) (*Term, error) {
+	var r = reader{input: input}
+
+	term, err := r.term()
+	if err != nil {
+		return nil, err
+	}
+
+	r.skipSpaces()
+
+	if r.pos != len(input) {
+		return nil, &syntaxError{r.pos}
+	}
+
+	return term, nil
+}
+
+// term reads a metarepresented term in mixfix form.
+func (r *reader) term() (*Term, error) {
+	r.skipSpaces()
+
+	var start = r.pos
+	var token = r.token("[],")
+
+	if token == "" {
+		return nil, &syntaxError{start}
+	}
+
+	if r.peek() != '[' {
+		return leaf(token), nil
+	}
+
+	r.pos++
+
+	var term = &Term{Kind: Application, Name: QidName(token)}
+
+	for {
+		arg, err := r.term()
+		if err != nil {
+			return nil, err
+		}
+
+		term.Args = append(term.Args, arg)
+		r.skipSpaces()
+
+		switch r.peek() {
+			case ',' : r.pos++
+			case ']' : r.pos++; return term, nil
+			default  : return nil, &syntaxError{r.pos}
+		}
+	}
+}
+
+// FromNode converts a metarepresented term in prefix form, where applications
+// are written like _[_]('f, _,_('X:Nat, '0.Nat)), to a term.
+func FromNode(node *Node) (*Term, error) {
+	if node.Is("_[_]", 2) {
+		var term = &Term{Kind: Application, Name: QidName(node.Args[0].Symbol)}
+
+		for _, argNode := range node.Args[1].List("_,_") {
+			arg, err := FromNode(argNode)
+			if err != nil {
+				return nil, err
+			}
+
+			term.Args = append(term.Args, arg)
+		}
+
+		return term, nil
+	}
+
+	if len(node.Args) > 0 {
+		return nil, ErrSyntax
+	}
+
+	return leaf(node.Symbol), nil
+}
+
+// leaf converts the token of a constant or variable to a term.
+func leaf(token string) *Term {
+	if isEmpty(token) {
+		return &Term{Kind: Empty, Name: token}
+	}
+
+	// The name and the sort are separated by the last unescaped dot
+	// (for constants) or colon (for variables) outside string literals
+	var separator = -1
+
+	for i := 0; i < len(token); i++ {
+		switch token[i] {
+			case '`' :
+				i++
+			case '"' :
+				for i++; i < len(token) && token[i] != '"'; i++ {
+					if token[i] == '\\' {
+						i++
+					}
+				}
+			case '.', ':' :
+				separator = i
+		}
+	}
+
+	if separator < 0 {
+		return &Term{Kind: Constant, Name: QidName(token)}
+	}
+
+	var kind = Constant
+
+	if token[separator] == ':' {
+		kind = Variable
+	}
+
+	return &Term{
+		Kind: kind,
+		Name: QidName(token[:separator]),
+		Sort: Unescape(token[separator+1:]),
+	}
+}
+
+// String prints the term back in the mixfix metarepresentation.
+func (t *Term) String() string {
+	var builder strings.Builder
+	t.write(&builder)
+	return builder.String()
+}
+
+func (t *Term) write(builder *strings.Builder) {
+	switch t.Kind {
+		case Empty :
+			builder.WriteString(t.Name)
+		case Constant :
+			builder.WriteString(Qid(t.Name))
+			if t.Sort != "" {
+				builder.WriteString("." + Escape(t.Sort))
+			}
+		case Variable :
+			builder.WriteString(Qid(t.Name) + ":" + Escape(t.Sort))
+		case Application :
+			builder.WriteString(Qid(t.Name))
+			builder.WriteByte('[')
+
+			for i, arg := range t.Args {
+				if i > 0 {
+					builder.WriteString(", ")
+				}
+				arg.write(builder)
+			}
+
+			builder.WriteByte(']')
+	}
+}
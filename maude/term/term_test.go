@@ -0,0 +1,119 @@
+package term
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Constructors of terms for the tests
+func constant(name, sort string) *Term       { return &Term{Constant, name, sort, nil} }
+func variable(name, sort string) *Term       { return &Term{Variable, name, sort, nil} }
+func apply(name string, args ...*Term) *Term { return &Term{Application, name, "", args} }
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected *Term
+	}{
+		{"'a", constant("a", "")},
+		{"'0.Zero", constant("0", "Zero")},
+		{"'X:Nat", variable("X", "Nat")},
+		{"'_+_['X:Nat, 's_['0.Zero]]",
+			apply("_+_", variable("X", "Nat"), apply("s_", constant("0", "Zero")))},
+		{"'f[empty, nil, none]",
+			apply("f", &Term{Kind: Empty, Name: "empty"}, &Term{Kind: Empty, Name: "nil"}, &Term{Kind: Empty, Name: "none"})},
+		// The sort is separated by the last dot
+		{"'a.b.Nat", constant("a.b", "Nat")},
+		{"'1.5e-1.Float", constant("1.5e-1", "Float")},
+		{"'-_['-2.0e+10.FiniteFloat]", apply("-_", constant("-2.0e+10", "FiniteFloat"))},
+		// Escaped symbols
+		{"'_`,_['a.Elt, 'b.Elt]", apply("_,_", constant("a", "Elt"), constant("b", "Elt"))},
+		{"'`{_`}['X:`[Nat`]]", apply("{_}", variable("X", "[Nat]"))},
+		{"'`[`].`[Foo`]", constant("[]", "[Foo]")},
+		// String literals with dots, colons, brackets and quotes
+		{"'\"a.b:c\".String", constant("\"a.b:c\"", "String")},
+		{"'f['\"[x, y]\".String]", apply("f", constant("\"[x, y]\"", "String"))},
+		{"'\"say \\\"hi.\\\"\".String", constant("\"say \\\"hi.\\\"\"", "String")},
+		// Blanks and line breaks
+		{"\n'f[\n\t'a.Nat,\n\t'b.Nat]\n", apply("f", constant("a", "Nat"), constant("b", "Nat"))},
+	}
+
+	for _, test := range tests {
+		result, err := Parse(test.input)
+
+		if err != nil {
+			t.Errorf("Parse(%q) fails with %v", test.input, err)
+		} else if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Parse(%q) = %s, expected %s", test.input, result, test.expected)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	var inputs = []string{
+		"", "'f[", "'f['a", "'f['a,]", "'f[]", "'f['a]]", "'a 'b", "]", "'f['a 'b]", "'\"unterminated",
+	}
+
+	for _, input := range inputs {
+		if result, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) = %s, expected a syntax error", input, result)
+		} else if !errors.Is(err, ErrSyntax) {
+			t.Errorf("Parse(%q) fails with %v, expected a syntax error", input, err)
+		}
+	}
+}
+
+func TestTermString(t *testing.T) {
+	var inputs = []string{
+		"'a",
+		"'X:Nat",
+		"'_+_['X:Nat, 's_['0.Zero]]",
+		"'f[empty, nil]",
+		"'1.5e-1.Float",
+		"'_`,_['a.Elt, 'b.Elt]",
+		"'`{_`}['X:`[Nat`]]",
+		"'\"say \\\"hi.\\\"\".String",
+	}
+
+	for _, input := range inputs {
+		if result, err := Parse(input); err != nil {
+			t.Errorf("Parse(%q) fails with %v", input, err)
+		} else if printed := result.String(); printed != input {
+			t.Errorf("Parse(%q) is printed as %q", input, printed)
+		}
+	}
+}
+
+func TestFromNode(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected *Term
+	}{
+		{"'0.Zero", constant("0", "Zero")},
+		{"_[_]('_+_, _`,_('X:Nat, _[_]('s_, '0.Zero)))",
+			apply("_+_", variable("X", "Nat"), apply("s_", constant("0", "Zero")))},
+		{"_[_]('`{_`}, 'X:`[Nat`])", apply("{_}", variable("X", "[Nat]"))},
+	}
+
+	for _, test := range tests {
+		parsed, err := ParsePrefix(test.input)
+		if err != nil {
+			t.Errorf("ParsePrefix(%q) fails with %v", test.input, err)
+			continue
+		}
+
+		if result, err := FromNode(parsed); err != nil {
+			t.Errorf("FromNode(%q) fails with %v", test.input, err)
+		} else if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("FromNode(%q) = %s, expected %s", test.input, result, test.expected)
+		}
+	}
+
+	// Only applications of _[_] have arguments
+	if parsed, err := ParsePrefix("noParse(5)"); err != nil {
+		t.Errorf("ParsePrefix(\"noParse(5)\") fails with %v", err)
+	} else if _, err := FromNode(parsed); !errors.Is(err, ErrSyntax) {
+		t.Errorf("FromNode(noParse(5)) gives %v, expected a syntax error", err)
+	}
+}
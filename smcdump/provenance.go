@@ -0,0 +1,98 @@
+package smcdump
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// Suffix of the file next to a dump where its provenance is recorded
+const provenanceSuffix = ".meta.json"
+
+// Provenance describes how a dump was generated, since the dump itself only
+// contains the initial term and the formula.
+type Provenance struct {
+	// Source is the Maude file where the model is defined (a path or, for
+	// the dumps of the web interface, its web URL)
+	Source       string        `json:"source"`
+	// SourceHash is the SHA-256 hash of the source file (in hexadecimal)
+	SourceHash   string        `json:"sourceHash"`
+	Module       string        `json:"module"`
+	InitialTerm  string        `json:"initial"`
+	LtlFormula   string        `json:"formula"`
+	Strategy     string        `json:"strategy"`
+	Opaques      string        `json:"opaques"`
+	MaudeVersion string        `json:"maudeVersion"`
+	StartTime    time.Time     `json:"startTime"`
+	EndTime      time.Time     `json:"endTime"`
+	// Statistics of the model checker (if known)
+	Rewrites     int64         `json:"rewrites,omitempty"`
+	CpuTime      time.Duration `json:"cpuTime,omitempty"`
+	RealTime     time.Duration `json:"realTime,omitempty"`
+}
+
+// ProvenancePath is the path of the provenance file of the given dump.
+func ProvenancePath(dumpPath string) string {
+	return dumpPath + provenanceSuffix
+}
+
+// WriteProvenance writes the provenance file of the given dump.
+func WriteProvenance(dumpPath string, provenance *Provenance) error {
+	content, err := json.MarshalIndent(provenance, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(ProvenancePath(dumpPath), content, 0644)
+}
+
+// ReadProvenance reads the provenance file of the given dump.
+func ReadProvenance(dumpPath string) (*Provenance, error) {
+	content, err := ioutil.ReadFile(ProvenancePath(dumpPath))
+	if err != nil {
+		return nil, err
+	}
+
+	var provenance Provenance
+
+	if err := json.Unmarshal(content, &provenance); err != nil {
+		return nil, err
+	}
+
+	return &provenance, nil
+}
+
+// HashFile calculates the SHA-256 hash of a file (in hexadecimal).
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	var hash = sha256.New()
+
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// SourceChanged tells whether the source file, found in the given path, has
+// changed or it is no longer available since the dump was generated.
+func (p *Provenance) SourceChanged(path string) bool {
+	hash, err := HashFile(path)
+
+	return err != nil || hash != p.SourceHash
+}
+
+// Duration is the time taken to generate the dump (rounded to milliseconds).
+func (p *Provenance) Duration() time.Duration {
+	return p.EndTime.Sub(p.StartTime).Round(time.Millisecond)
+}
@@ -0,0 +1,147 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// DefaultSimplifierFile is the Maude file where the simplifier operator is
+// looked for if no other is given.
+const DefaultSimplifierFile = "smcview-simpl.maude"
+
+// SimplifierSpec describes a simplifier in a chain, as read from the
+// configuration file.
+type SimplifierSpec struct {
+	// Type is maude, regex or elide
+	Type string `json:"type"`
+	// File, Module and Op define the Maude simplifier
+	File   string `json:"file"`
+	Module string `json:"module"`
+	Op     string `json:"op"`
+	// Timeout is the maximum time in seconds to simplify a term with Maude
+	// (DefaultSimplifierTimeout if zero, unlimited if negative)
+	Timeout float64 `json:"timeout"`
+	// Rules are the replacements of the regex simplifier
+	Rules []RewriteRule `json:"rules"`
+	// MaxLength is the maximum length of the terms for the elide simplifier
+	MaxLength int `json:"maxLength"`
+}
+
+// ReadSimplifierConfig reads a chain of simplifier specifications from a
+// configuration file in JSON format, like
+//
+//	[{"type": "maude", "file": "simpl.maude", "op": "project"},
+//	 {"type": "regex", "rules": [{"pattern": "\\s+", "replace": " "}]},
+//	 {"type": "elide", "maxLength": 80}]
+func ReadSimplifierConfig(path string) ([]SimplifierSpec, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []SimplifierSpec
+
+	if err := json.Unmarshal(content, &specs); err != nil {
+		return nil, fmt.Errorf("bad simplifier configuration in '%s': %v", path, err)
+	}
+
+	return specs, nil
+}
+
+// NeedsMaude tells whether any of the specifications is a Maude simplifier.
+func NeedsMaude(specs []SimplifierSpec) bool {
+	for _, spec := range specs {
+		if spec.Type == "maude" {
+			return true
+		}
+	}
+
+	return false
+}
+
+type simplifierChain []TermSimplifier
+
+// NewSimplifierChain constructs a simplifier that applies the simplifiers
+// described by the specifications in order. The Maude simplifiers are run
+// by the executable in maudePath.
+func NewSimplifierChain(specs []SimplifierSpec, maudePath string) (TermSimplifier, error) {
+	var chain = make(simplifierChain, 0, len(specs))
+
+	for _, spec := range specs {
+		var simplifier TermSimplifier
+		var err error
+
+		switch spec.Type {
+			case "maude" :
+				var file = spec.File
+
+				if file == "" {
+					file = DefaultSimplifierFile
+				}
+
+				if spec.Op == "" {
+					err = fmt.Errorf("no operator given for the Maude simplifier in '%s'", file)
+					break
+				}
+
+				var ms *MaudeSimplifier
+
+				if ms, err = NewSimplifier(spec.Op, file, spec.Module, maudePath); err == nil {
+					if spec.Timeout != 0 {
+						ms.Timeout = time.Duration(spec.Timeout * float64(time.Second))
+					}
+
+					simplifier = ms
+				}
+			case "regex" :
+				simplifier, err = NewRegexSimplifier(spec.Rules)
+			case "elide" :
+				simplifier = NewElisionSimplifier(spec.MaxLength)
+			default :
+				err = fmt.Errorf("unknown simplifier type '%s' (maude, regex or elide expected)", spec.Type)
+		}
+
+		if err != nil {
+			chain.Close()
+			return nil, err
+		}
+
+		chain = append(chain, simplifier)
+	}
+
+	// A single simplifier does not need a chain
+	if len(chain) == 1 {
+		return chain[0], nil
+	}
+
+	return chain, nil
+}
+
+// Simplify applies the simplifiers of the chain in order.
+func (sc simplifierChain) Simplify(term string) string {
+	for _, simplifier := range sc {
+		term = simplifier.Simplify(term)
+	}
+
+	return term
+}
+
+// SimplifyAll applies the simplifiers of the chain in order to all the terms.
+func (sc simplifierChain) SimplifyAll(terms []string) []string {
+	terms = append([]string(nil), terms...)
+
+	for _, simplifier := range sc {
+		terms = simplifier.SimplifyAll(terms)
+	}
+
+	return terms
+}
+
+// Close closes all the simplifiers of the chain.
+func (sc simplifierChain) Close() {
+	for _, simplifier := range sc {
+		simplifier.Close()
+	}
+}
@@ -2,16 +2,51 @@
 package util
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/ningit/smcview/maude"
 	"log"
 	"os"
 	"strings"
+	"sync"
+	"time"
+)
+
+// Maximum number of terms simplified in a single Maude command
+const batchSize = 256
+
+// Maximum number of timeouts given to a batch, after which its terms are
+// simplified one by one (a hanging term would otherwise block the batch for
+// the timeout of all its terms)
+const batchTimeouts = 3
+
+// DefaultSimplifierTimeout is the default maximum time for simplifying a term.
+const DefaultSimplifierTimeout = 5 * time.Second
+
+// Module where the simplified terms are collected in a single term by a
+// polymorphic constructor, to be reduced all in a single command
+const (
+	batchModuleName = "%SMCVIEW-SIMPL-BATCH"
+	batchModule     = `smod %%SMCVIEW-SIMPL-BATCH is
+	including %s .
+	sort %%SimplBatch .
+	op %%end : -> %%SimplBatch [ctor] .
+	op $smcview$(_)_ : Universal %%SimplBatch -> %%SimplBatch [ctor poly (1)] .
+endsm`
+	batchItem = "$smcview$("
+	batchEnd  = "%end"
 )
 
 // TermSimplifier simplifies terms.
 type TermSimplifier interface {
 	// Simplify simplifies a term
 	Simplify(string) string
+	// SimplifyAll simplifies a list of terms (more efficiently than
+	// simplifying them one by one)
+	SimplifyAll([]string) []string
+	// Close releases the resources used by the simplifier
+	Close()
 }
 
 type dummySimplifier struct {}
@@ -27,49 +62,367 @@ func (vs *dummySimplifier) Simplify(term string) string {
 	return term
 }
 
-type termReducer struct {
-	maudec     *maude.Client
+// SimplifyAll is the identity for the dummy simplifier.
+func (vs *dummySimplifier) SimplifyAll(terms []string) []string {
+	return append([]string(nil), terms...)
+}
+
+// Close does nothing for the dummy simplifier.
+func (vs *dummySimplifier) Close() {
+}
+
+// MaudeSimplifier simplifies terms by reducing an operator applied to them
+// in a Maude interpreter of its own, independent of those used by the rest
+// of the program. It can be stopped and started again, and terms are left
+// unchanged if Maude fails or does not finish in time.
+type MaudeSimplifier struct {
+	maudePath  string
+	file       string
 	simplifier string
+	// Module where terms are reduced (the current one if empty)
+	module     string
+	// Timeout is the maximum time to simplify a term (unlimited if zero)
+	Timeout    time.Duration
+	mutex      sync.Mutex
+	// Pool with a single interpreter (nil when stopped)
+	pool       *maude.Pool
+	// Interpreter where the batch module has been defined (the interpreter
+	// of the pool may be replaced if it crashes or is killed)
+	batchClient *maude.Client
+	// Whether the batch module cannot be defined, so that terms are
+	// always simplified one by one
+	noBatch     bool
 }
 
-// CreateSimplifier constructs a simplifier that uses Maude to simplify
-// terms. The simplification function should be defined in a file named
-// smcview-simp.maude in the current working directory and its name is given
-// by opname.
+// CreateSimplifier constructs the chain of simplifiers described by the
+// given specifications (see NewSimplifierChain). If it cannot be built, the
+// problem is logged and the dummy simplifier is returned instead.
 //
-// Be aware that the file smcview-simp.maude will be loaded and any command
-// within it will be executed.
-func CreateSimplifier(opname string, maudec *maude.Client) TermSimplifier {
-	// Fallbacks to the dummy simplifier when the requirements
-	// of the reduction simplifier are not met
-	if opname == "" || maudec == nil {
+// Be aware that the files of the Maude simplifiers will be loaded and any
+// command within them will be executed.
+func CreateSimplifier(specs []SimplifierSpec, maudePath string) TermSimplifier {
+	if len(specs) == 0 {
 		return &dummySimplifier{}
 	}
 
-	if _, err := os.Stat("smcview-simpl.maude"); err != nil {
-		log.Println("the file 'smcview-simp.maude' required by the simplifier is not available in the working directory.")
+	simplifier, err := NewSimplifierChain(specs, maudePath)
+	if err != nil {
+		log.Println("cannot create the simplifier:", err)
 		return &dummySimplifier{}
 	}
 
-	maudec.Start()
-	maudec.Load("smcview-simpl.maude")
+	return simplifier
+}
+
+// NewSimplifier constructs and starts a simplifier that reduces the operator
+// opname applied to the terms in a Maude interpreter where the given file has
+// been loaded. Terms are reduced in the given module or, if empty, in the
+// last module of the file.
+func NewSimplifier(opname, file, module, maudePath string) (*MaudeSimplifier, error) {
+	if maudePath == "" {
+		return nil, errors.New("Maude is not available for the simplifier")
+	}
+
+	var ms = &MaudeSimplifier{
+		maudePath:  maudePath,
+		file:       file,
+		simplifier: opname,
+		module:     module,
+		Timeout:    DefaultSimplifierTimeout,
+	}
+
+	if err := ms.Start(); err != nil {
+		return nil, err
+	}
 
-	return &termReducer{maudec, opname}
+	return ms, nil
+}
+
+// Start starts the interpreter of the simplifier, if not running.
+func (ms *MaudeSimplifier) Start() error {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	if ms.pool != nil {
+		return nil
+	}
+
+	if _, err := os.Stat(ms.file); err != nil {
+		return fmt.Errorf("the file '%s' required by the simplifier is not available", ms.file)
+	}
+
+	var ctx = context.Background()
+
+	pool, err := maude.NewPool(ctx, ms.maudePath, 1, []string{ms.file}, nil)
+	if err != nil {
+		return err
+	}
+
+	// The module is checked in advance to fail early
+	if ms.module != "" {
+		maudec, err := pool.Get(ctx)
+		if err != nil {
+			pool.Close(ctx)
+			return err
+		}
+
+		info, err := maudec.UpModule(ctx, ms.module, false)
+		pool.Put(maudec, err)
+
+		if err != nil || info == nil {
+			pool.Close(ctx)
+			return fmt.Errorf("the module '%s' of the simplifier is not defined in '%s'", ms.module, ms.file)
+		}
+	}
+
+	ms.pool, ms.batchClient, ms.noBatch = pool, nil, false
+
+	return nil
+}
+
+// Stop quits the interpreter of the simplifier. Terms are not simplified
+// until it is started again.
+func (ms *MaudeSimplifier) Stop() {
+	ms.mutex.Lock()
+	var pool = ms.pool
+	ms.pool = nil
+	ms.mutex.Unlock()
+
+	if pool != nil {
+		pool.Close(context.Background())
+	}
+}
+
+// Running tells whether the interpreter of the simplifier is started.
+func (ms *MaudeSimplifier) Running() bool {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	return ms.pool != nil
+}
+
+// Close stops the simplifier.
+func (ms *MaudeSimplifier) Close() {
+	ms.Stop()
+}
+
+// withTimeout returns a context limited by the timeout for the given number
+// of simplifications.
+func (ms *MaudeSimplifier) withTimeout(count int) (context.Context, context.CancelFunc) {
+	if ms.Timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+
+	return context.WithTimeout(context.Background(), ms.Timeout*time.Duration(count))
+}
+
+// get checks out the interpreter of the simplifier, returning it with the
+// pool it belongs to.
+func (ms *MaudeSimplifier) get() (*maude.Pool, *maude.Client, error) {
+	ms.mutex.Lock()
+	var pool = ms.pool
+	ms.mutex.Unlock()
+
+	if pool == nil {
+		return nil, nil, maude.ErrNotRunning
+	}
+
+	// Waiting for the replacement of a killed interpreter is not limited
+	// by the timeout, since the source file has to be loaded again
+	maudec, err := pool.Get(context.Background())
+
+	return pool, maudec, err
 }
 
 // Simplify reduces the given operator applied to the input term.
-func (tr *termReducer) Simplify(term string) string {
-	var result = tr.maudec.Reduce(tr.simplifier + "((" + term + "))")
-
-	if result.Ok {
-		// If the result is a string (or it seems to be),
-		// its quotes are removed
-		if result.Term[0] == '"' {
-			return strings.TrimPrefix(strings.TrimSuffix(result.Term, "\""), "\"")
+func (ms *MaudeSimplifier) Simplify(term string) string {
+	pool, maudec, err := ms.get()
+	if err != nil {
+		log.Println("the simplifier has failed:", err)
+		return term
+	}
+
+	defer pool.Put(maudec, nil)
+
+	return ms.reduce(maudec, term)
+}
+
+// SimplifyAll reduces the given operator applied to every input term. Terms
+// are reduced in batches with a single Maude command each, unless the batch
+// module cannot be defined, a term of the batch cannot be parsed or the
+// batch exceeds its deadline.
+func (ms *MaudeSimplifier) SimplifyAll(terms []string) []string {
+	var results = append([]string(nil), terms...)
+
+	pool, maudec, err := ms.get()
+	if err != nil {
+		log.Println("the simplifier has failed:", err)
+		return results
+	}
+
+	for start := 0; start < len(terms); start += batchSize {
+		var end = start + batchSize
+
+		if end > len(terms) {
+			end = len(terms)
+		}
+
+		if ms.reduceBatch(maudec, terms[start:end], results[start:end]) {
+			continue
+		}
+
+		for i := start; i < end; i++ {
+			// The interpreter is replaced if killed by a timeout
+			if !maudec.Running() {
+				pool.Put(maudec, nil)
+
+				if maudec, err = pool.Get(context.Background()); err != nil {
+					log.Println("the simplifier has failed:", err)
+					return results
+				}
+			}
+
+			results[i] = ms.reduce(maudec, terms[i])
 		}
+	}
+
+	pool.Put(maudec, nil)
+
+	return results
+}
+
+// reduce reduces the simplifier operator applied to a single term.
+func (ms *MaudeSimplifier) reduce(maudec *maude.Client, term string) string {
+	ctx, cancel := ms.withTimeout(1)
+	defer cancel()
+
+	var input = ms.simplifier + "((" + term + "))"
+	var result maude.ReduceResult
+	var err error
+
+	if ms.module == "" {
+		result, err = maudec.Reduce(ctx, input)
+	} else {
+		result, err = maudec.ReduceIn(ctx, ms.module, input)
+	}
+
+	if err != nil {
+		log.Println("the simplifier has failed:", err)
+		return term
+	}
 
-		return result.Term
+	if result.Ok && result.Term != "" {
+		return unquote(result.Term)
 	} else {
 		return term
 	}
 }
+
+// reduceBatch reduces the simplifier operator applied to some terms in
+// a single command, writing the results in the given slice. It returns
+// whether it has succeeded.
+func (ms *MaudeSimplifier) reduceBatch(maudec *maude.Client, terms, results []string) bool {
+	if !ms.defineBatch(maudec) {
+		return false
+	}
+
+	var timeouts = len(terms)
+
+	if timeouts > batchTimeouts {
+		timeouts = batchTimeouts
+	}
+
+	ctx, cancel := ms.withTimeout(timeouts)
+	defer cancel()
+
+	var builder strings.Builder
+
+	for _, term := range terms {
+		builder.WriteString(batchItem + ms.simplifier + "((" + term + "))) ")
+	}
+
+	builder.WriteString(batchEnd)
+
+	result, err := maudec.ReduceIn(ctx, batchModuleName, builder.String())
+
+	if err != nil || !result.Ok || !strings.HasSuffix(result.Term, batchEnd) {
+		return false
+	}
+
+	// The result is $smcview$(t1) ... $smcview$(tn) %end
+	var items = strings.Split(strings.TrimSuffix(result.Term, batchEnd), batchItem)
+
+	if len(items) != len(terms)+1 || strings.TrimSpace(items[0]) != "" {
+		return false
+	}
+
+	for i, item := range items[1:] {
+		item = strings.TrimSpace(item)
+
+		if !strings.HasSuffix(item, ")") {
+			return false
+		}
+
+		results[i] = unquote(item[:len(item)-1])
+	}
+
+	return true
+}
+
+// defineBatch defines the batch module in the given interpreter, if not
+// already done, and returns whether it is available.
+func (ms *MaudeSimplifier) defineBatch(maudec *maude.Client) bool {
+	if ms.noBatch {
+		return false
+	} else if ms.batchClient == maudec {
+		return true
+	}
+
+	ctx, cancel := ms.withTimeout(1)
+	defer cancel()
+
+	// The batch module extends the module where the simplifier is defined,
+	// and the current one remains selected
+	current, err := maudec.CurrentModuleName(ctx)
+	if err != nil {
+		return false
+	}
+
+	var module = ms.module
+
+	if module == "" {
+		module = current
+	}
+
+	messages, err := maudec.Capture(ctx, func(tx *maude.Client) error {
+		if _, err := tx.RawInput(ctx, fmt.Sprintf(batchModule, module)); err != nil {
+			return err
+		}
+
+		return tx.Select(ctx, current)
+	})
+
+	if err != nil {
+		return false
+	}
+
+	if maude.HasErrors(messages) {
+		log.Println("terms will be simplified one by one:", messages[0].Text)
+		ms.noBatch = true
+		return false
+	}
+
+	ms.batchClient = maudec
+	return true
+}
+
+// unquote removes the quotes of a result if it is a string (or it seems
+// to be).
+func unquote(term string) string {
+	if term != "" && term[0] == '"' {
+		return strings.TrimPrefix(strings.TrimSuffix(term, "\""), "\"")
+	}
+
+	return term
+}
+
@@ -0,0 +1,65 @@
+package util
+
+import (
+	"github.com/ningit/smcview/smcdump"
+	"sync"
+)
+
+// TermCache memoizes the simplified terms of a dump by their index in its
+// string table, so that terms shared by many states are simplified once.
+// A cache must only be used with a single dump.
+type TermCache struct {
+	simplifier TermSimplifier
+	mutex      sync.Mutex
+	terms      map[int32]string
+}
+
+// NewTermCache creates an empty cache for the given simplifier.
+func NewTermCache(simplifier TermSimplifier) *TermCache {
+	return &TermCache{simplifier: simplifier, terms: make(map[int32]string)}
+}
+
+// Prefetch simplifies all together the terms with the given indices that
+// are not in the cache yet.
+func (c *TermCache) Prefetch(dump smcdump.SmcDump, indices []int32) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var missing = make([]int32, 0)
+	var seen = make(map[int32]struct{})
+	var terms = make([]string, 0)
+
+	for _, index := range indices {
+		if _, cached := c.terms[index]; !cached {
+			if _, dup := seen[index]; !dup {
+				seen[index] = struct{}{}
+				missing = append(missing, index)
+				terms = append(terms, dump.GetString(index))
+			}
+		}
+	}
+
+	if len(terms) == 0 {
+		return
+	}
+
+	for i, term := range c.simplifier.SimplifyAll(terms) {
+		c.terms[missing[i]] = term
+	}
+}
+
+// Term returns the simplified term with the given index, which is
+// simplified now if not in the cache.
+func (c *TermCache) Term(dump smcdump.SmcDump, index int32) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	term, cached := c.terms[index]
+
+	if !cached {
+		term = c.simplifier.Simplify(dump.GetString(index))
+		c.terms[index] = term
+	}
+
+	return term
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// RewriteRule is a regular expression replacement for the regex simplifier.
+// The replacement can refer to the submatches of the pattern with $1, $2...
+type RewriteRule struct {
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace"`
+}
+
+type regexSimplifier struct {
+	patterns     []*regexp.Regexp
+	replacements []string
+}
+
+// NewRegexSimplifier constructs a simplifier that applies some regular
+// expression replacements in order.
+func NewRegexSimplifier(rules []RewriteRule) (TermSimplifier, error) {
+	var rs = &regexSimplifier{
+		make([]*regexp.Regexp, len(rules)),
+		make([]string, len(rules)),
+	}
+
+	for i, rule := range rules {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+
+		rs.patterns[i] = pattern
+		rs.replacements[i] = rule.Replace
+	}
+
+	return rs, nil
+}
+
+// Simplify applies the replacements to the term.
+func (rs *regexSimplifier) Simplify(term string) string {
+	for i, pattern := range rs.patterns {
+		term = pattern.ReplaceAllString(term, rs.replacements[i])
+	}
+
+	return term
+}
+
+// SimplifyAll applies the replacements to every term.
+func (rs *regexSimplifier) SimplifyAll(terms []string) []string {
+	return simplifyEach(rs, terms)
+}
+
+// Close does nothing for the regex simplifier.
+func (rs *regexSimplifier) Close() {
+}
+
+type elisionSimplifier struct {
+	maxLength int
+}
+
+// Text that replaces the middle of the elided terms
+const ellipsis = " ... "
+
+// NewElisionSimplifier constructs a simplifier that elides the middle of
+// the terms longer than maxLength characters.
+func NewElisionSimplifier(maxLength int) TermSimplifier {
+	return &elisionSimplifier{maxLength}
+}
+
+// Simplify keeps the beginning and the end of the term if it is too long.
+func (es *elisionSimplifier) Simplify(term string) string {
+	var length = utf8.RuneCountInString(term)
+
+	if length <= es.maxLength || es.maxLength <= len(ellipsis) {
+		return term
+	}
+
+	// The characters kept at both sides of the ellipsis
+	var runes = []rune(term)
+	var kept = es.maxLength - len(ellipsis)
+	var head = (kept + 1) / 2
+
+	return string(runes[:head]) + ellipsis + string(runes[length-(kept-head):])
+}
+
+// SimplifyAll elides every term.
+func (es *elisionSimplifier) SimplifyAll(terms []string) []string {
+	return simplifyEach(es, terms)
+}
+
+// Close does nothing for the elision simplifier.
+func (es *elisionSimplifier) Close() {
+}
+
+// simplifyEach simplifies the terms one by one.
+func simplifyEach(simplifier TermSimplifier, terms []string) []string {
+	var results = make([]string, len(terms))
+
+	for i, term := range terms {
+		results[i] = simplifier.Simplify(term)
+	}
+
+	return results
+}
@@ -0,0 +1,247 @@
+package webui
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Name of the cookie where the access token is kept by the browser
+const tokenCookie = "smcview-token"
+
+// EnableToken protects the interface with a random access token, which
+// is included in the URL returned by BaseUrl.
+func (s *WebUi) EnableToken() error {
+	var buffer = make([]byte, 24)
+
+	if _, err := rand.Read(buffer); err != nil {
+		return err
+	}
+
+	s.token = hex.EncodeToString(buffer)
+
+	return nil
+}
+
+// EnablePasswords protects the interface with HTTP basic authentication,
+// whose users and passwords are read from the given file. Each line of
+// this file should be of the form user:password, where the password can be
+// also given as sha256: followed by the hexadecimal SHA-256 hash of the
+// password. Empty lines and lines starting with # are ignored.
+func (s *WebUi) EnablePasswords(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	var passwords = make(map[string][]byte)
+	var scanner = bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		var line = strings.TrimSpace(scanner.Text())
+
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var colon = strings.IndexByte(line, ':')
+		if colon <= 0 {
+			return errors.New("bad line in password file (user:password expected)")
+		}
+
+		var user, password = line[:colon], line[colon+1:]
+
+		// Passwords are stored hashed in memory anyway
+		if strings.HasPrefix(password, "sha256:") {
+			hash, err := hex.DecodeString(password[7:])
+			if err != nil || len(hash) != sha256.Size {
+				return errors.New("bad SHA-256 hash in password file")
+			}
+			passwords[user] = hash
+		} else {
+			var hash = sha256.Sum256([]byte(password))
+			passwords[user] = hash[:]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if len(passwords) == 0 {
+		return errors.New("the password file does not contain any user")
+	}
+
+	s.passwords = passwords
+
+	return nil
+}
+
+// BaseUrl is the address of the interface for the given host name,
+// including the access token if required.
+func (s *WebUi) BaseUrl(host string) string {
+	var scheme = "http"
+
+	if s.TLS {
+		scheme = "https"
+	}
+
+	var url = scheme + "://" + net.JoinHostPort(host, s.portNumber()) + "/"
+
+	if s.token != "" {
+		url += "?token=" + s.token
+	}
+
+	return url
+}
+
+// authenticate checks whether the request is authorized to access the
+// interface, writing an error response if it is not.
+func (s *WebUi) authenticate(writer http.ResponseWriter, request *http.Request) bool {
+	if s.token != "" {
+		if !s.checkToken(writer, request) {
+			http.Error(writer, "Unauthorized (use the URL with the access token printed at startup)", 401)
+			return false
+		}
+	}
+
+	if s.passwords != nil {
+		user, password, ok := request.BasicAuth()
+		var hash = sha256.Sum256([]byte(password))
+
+		if expected, known := s.passwords[user]; !ok || !known ||
+			subtle.ConstantTimeCompare(hash[:], expected) != 1 {
+			writer.Header().Set("WWW-Authenticate", "Basic realm=\"smcview\", charset=\"UTF-8\"")
+			http.Error(writer, "Unauthorized", 401)
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkToken checks the access token, which is received in the URL the
+// first time and then stored in a cookie.
+func (s *WebUi) checkToken(writer http.ResponseWriter, request *http.Request) bool {
+	if cookie, err := request.Cookie(tokenCookie); err == nil &&
+		subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(s.token)) == 1 {
+		return true
+	}
+
+	if subtle.ConstantTimeCompare([]byte(request.URL.Query().Get("token")), []byte(s.token)) == 1 {
+		http.SetCookie(writer, &http.Cookie{
+			Name:     tokenCookie,
+			Value:    s.token,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   s.TLS,
+			SameSite: http.SameSiteStrictMode,
+		})
+		return true
+	}
+
+	return false
+}
+
+// generateCertificate generates a self-signed certificate and its private
+// key in a private directory, and returns their paths. They are not written
+// in the temporary directory because its files can be downloaded with tmp:
+// URLs.
+func (s *WebUi) generateCertificate() (string, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return "", "", err
+	}
+
+	var template = x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "smcview"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+
+	// The listening address is also included in the certificate
+	if ip := net.ParseIP(s.Address); ip != nil && !ip.IsUnspecified() {
+		template.IPAddresses = append(template.IPAddresses, ip)
+	} else if s.Address != "" && ip == nil {
+		template.DNSNames = append(template.DNSNames, s.Address)
+	}
+
+	if hostname, err := os.Hostname(); err == nil {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+
+	certDer, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return "", "", err
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return "", "", err
+	}
+
+	// TempDir creates the directory with 0700 permissions
+	certDir, err := ioutil.TempDir("", "maude-smc-tls")
+	if err != nil {
+		return "", "", err
+	}
+
+	s.certDir = certDir
+
+	var certPath = filepath.Join(certDir, "cert.pem")
+	var keyPath = filepath.Join(certDir, "key.pem")
+
+	if err := writePem(certPath, "CERTIFICATE", certDer); err != nil {
+		return "", "", err
+	}
+
+	if err := writePem(keyPath, "EC PRIVATE KEY", keyDer); err != nil {
+		return "", "", err
+	}
+
+	return certPath, keyPath, nil
+}
+
+func writePem(path, blockType string, content []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	err = pem.Encode(file, &pem.Block{Type: blockType, Bytes: content})
+
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
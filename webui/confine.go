@@ -0,0 +1,94 @@
+package webui
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolvePath makes a path absolute and resolves the symbolic links in
+// it. If the path does not exist, the links of its longest existing prefix
+// are resolved.
+func resolvePath(hostpath string) (string, error) {
+	hostpath, err := filepath.Abs(hostpath)
+	if err != nil {
+		return "", err
+	}
+
+	var rest = ""
+
+	for {
+		resolved, err := filepath.EvalSymlinks(hostpath)
+
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		var parent = filepath.Dir(hostpath)
+
+		// The root of the filesystem does not exist
+		if parent == hostpath {
+			return "", err
+		}
+
+		rest = filepath.Join(filepath.Base(hostpath), rest)
+		hostpath = parent
+	}
+}
+
+// InsideDir checks whether a path is inside the given directory (or it is
+// the directory itself) after resolving the symbolic links in both.
+// Paths are compared by components, so that /data does not contain
+// /database.
+func InsideDir(root, hostpath string) bool {
+	root, err := resolvePath(root)
+	if err != nil {
+		return false
+	}
+
+	hostpath, err = resolvePath(hostpath)
+	if err != nil {
+		return false
+	}
+
+	relpath, err := filepath.Rel(root, hostpath)
+	if err != nil {
+		return false
+	}
+
+	return relpath != ".." && !strings.HasPrefix(relpath, ".."+string(filepath.Separator)) &&
+		!filepath.IsAbs(relpath)
+}
+
+// confine checks that a host path is inside the given root directory
+// before the server accesses it. Rejected attempts are logged. An empty
+// root admits any path.
+func confine(root, hostpath string) bool {
+	if root == "" || InsideDir(root, hostpath) {
+		return true
+	}
+
+	log.Printf("rejected access to %q outside %q", hostpath, root)
+
+	return false
+}
+
+// confinedPath translates a web URL to a path in the host machine like
+// web2NativeUrl, but it also checks that the resulting path is confined
+// to the root directory. The empty string is returned otherwise.
+func (s *WebUi) confinedPath(webUrl string) (string, bool) {
+	hostpath, isSpecial := s.web2NativeUrl(webUrl)
+
+	if hostpath == "" || isSpecial {
+		return hostpath, isSpecial
+	}
+
+	if !confine(s.RootDir, hostpath) {
+		return "", false
+	}
+
+	return hostpath, false
+}
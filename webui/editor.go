@@ -0,0 +1,102 @@
+package webui
+
+import (
+	"encoding/json"
+	"github.com/ningit/smcview/maude"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// editableSource translates the URL of a source file to be read or written
+// by the editor. Only existing Maude files accessible by the server (under
+// the root directory or uploaded) can be edited.
+func (s *WebUi) editableSource(url string) string {
+	var hostpath = s.translatePath(url)
+
+	if hostpath == "" || filepath.Ext(hostpath) != ".maude" {
+		return ""
+	}
+
+	if stat, err := os.Stat(hostpath); err != nil || !stat.Mode().IsRegular() {
+		return ""
+	}
+
+	return hostpath
+}
+
+// writableSource translates the URL of a source file to be saved by the
+// editor. Writes are confined to the root directory, so that saving is only
+// possible if one is given, and to the directories of the uploads.
+func (s *WebUi) writableSource(url string) string {
+	var hostpath = s.editableSource(url)
+
+	if hostpath == "" {
+		return ""
+	}
+
+	if strings.HasPrefix(url, "tmp:") {
+		if confine(s.tempDir, hostpath) && strings.HasPrefix(url[4:], "upload") {
+			return hostpath
+		}
+	} else if s.RootDir != "" && confine(s.RootDir, hostpath) {
+		return hostpath
+	}
+
+	return ""
+}
+
+func (s *WebUi) handleReadSource(writer http.ResponseWriter, request *http.Request) {
+	var hostpath = s.editableSource(request.FormValue("url"))
+
+	if hostpath == "" {
+		http.Error(writer, "Bad request", 400)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(writer, request, hostpath)
+}
+
+// handleSaveSource writes the source file edited in the browser and
+// reloads it in Maude, returning its modules and the messages printed
+// by Maude while loading it.
+func (s *WebUi) handleSaveSource(writer http.ResponseWriter, request *http.Request) {
+	var (
+		givenfile = request.FormValue("url")
+		content   = request.FormValue("content")
+		hostpath  = s.writableSource(givenfile)
+	)
+
+	if hostpath == "" {
+		http.Error(writer, "Saving is only allowed for uploaded files and inside the root directory (-rootdir)", 403)
+		return
+	}
+
+	if int64(len(content)) > s.MaxUploadSize {
+		http.Error(writer, "Request entity too large", 413)
+		return
+	}
+
+	if err := ioutil.WriteFile(hostpath, []byte(content), 0644); err != nil {
+		http.Error(writer, "The file cannot be written", 403)
+		return
+	}
+
+	modules, messages, err := s.loadSource(request.Context(), hostpath, true)
+	if err != nil {
+		s.reportMaudeError(writer, err)
+		return
+	}
+
+	s.setFileLoaded(givenfile)
+
+	writer.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(writer).Encode(struct {
+		Modules  []maude.ModuleInfo `json:"modules"`
+		Messages []maude.Message    `json:"messages"`
+	}{modules, messages})
+}
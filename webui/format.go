@@ -0,0 +1,93 @@
+package webui
+
+import (
+	"strings"
+	"unicode"
+)
+
+// termToken is a fragment of a term or strategy with a syntactic class,
+// so that the browser can format it without interpreting it as markup.
+type termToken struct {
+	Text  string `json:"text"`
+	// Class is symbol, string, number, qid, punct or space
+	Class string `json:"class"`
+}
+
+// tokenizeTerm splits a term or strategy printed by Maude into tokens for
+// its formatted display in the browser.
+func tokenizeTerm(text string) []termToken {
+	var tokens = make([]termToken, 0)
+
+	for pos := 0; pos < len(text); {
+		var end = pos + 1
+		var class string
+
+		switch char := text[pos]; {
+			case char == '"' :
+				// String literals with escaped characters
+				for end < len(text) && text[end] != '"' {
+					if text[end] == '\\' {
+						end++
+					}
+					end++
+				}
+
+				if end < len(text) {
+					end++
+				} else {
+					end = len(text)
+				}
+
+				class = "string"
+			case strings.IndexByte("()[]{},", char) >= 0 :
+				class = "punct"
+			case char == '`' && end < len(text) :
+				// Backquotes escape the following character
+				end = tokenEnd(text, end+1)
+				class = "symbol"
+			case unicode.IsSpace(rune(char)) :
+				for end < len(text) && unicode.IsSpace(rune(text[end])) {
+					end++
+				}
+
+				class = "space"
+			default :
+				end = tokenEnd(text, end)
+				class = tokenClass(text[pos:end])
+		}
+
+		tokens = append(tokens, termToken{text[pos:end], class})
+		pos = end
+	}
+
+	return tokens
+}
+
+// tokenEnd finds the end of the identifier that continues at pos.
+func tokenEnd(text string, pos int) int {
+	for pos < len(text) && !unicode.IsSpace(rune(text[pos])) &&
+		strings.IndexByte("()[]{},\"", text[pos]) < 0 {
+		if text[pos] == '`' {
+			pos++
+		}
+		pos++
+	}
+
+	if pos > len(text) {
+		pos = len(text)
+	}
+
+	return pos
+}
+
+// tokenClass tells the class of an identifier.
+func tokenClass(token string) string {
+	switch {
+		case token[0] == '\'' && len(token) > 1 :
+			return "qid"
+		case strings.TrimLeft(token, "-0123456789.e/") == "" && strings.ContainsAny(token, "0123456789") :
+			return "number"
+		default :
+			return "symbol"
+	}
+}
@@ -0,0 +1,355 @@
+package webui
+
+import (
+	"encoding/json"
+	"github.com/ningit/smcview/maude"
+	"github.com/ningit/smcview/smcdump"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Identifiers of runs are derived from their timestamps
+var (
+	runIdRegex  = regexp.MustCompile("^[0-9]{8}-[0-9]{6}(?:-[0-9]+)?$")
+	runIdFormat = "20060102-150405"
+)
+
+// runInfo describes a model checker run stored in the history.
+type runInfo struct {
+	Id   string   `json:"id"`
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+	// Input data of the model checker
+	Input inputData `json:"input"`
+	// Summary of the result
+	EndTime        time.Time `json:"endTime"`
+	Holds          bool      `json:"holds"`
+	NumberOfStates int       `json:"states"`
+	PathLength     int       `json:"pathLength"`
+	CycleLength    int       `json:"cycleLength"`
+	// Statistics printed by Maude
+	Stats          maude.Stats `json:"stats"`
+}
+
+// runDir is the directory where the files of a run are stored, or the
+// empty string if the identifier is not valid or the history is disabled.
+func (s *WebUi) runDir(id string) string {
+	if s.DataDir == "" || !runIdRegex.MatchString(id) {
+		return ""
+	}
+
+	var hostpath = filepath.Join(s.DataDir, "runs", id)
+
+	if !confine(s.DataDir, hostpath) {
+		return ""
+	}
+
+	return hostpath
+}
+
+// runDump is the path of the dump of a run, or of its witness file if it
+// is an invariant check.
+func (s *WebUi) runDump(id string) string {
+	var dir = s.runDir(id)
+
+	if dir == "" {
+		return ""
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, witnessFile)); err == nil {
+		return filepath.Join(dir, witnessFile)
+	}
+
+	return filepath.Join(dir, "dump")
+}
+
+// writeRunInfo writes the description of a run in its directory.
+func writeRunInfo(dir string, info *runInfo) error {
+	content, err := json.MarshalIndent(info, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, "run.json"), content, 0644)
+}
+
+// readRunInfo reads the description of a run from its directory.
+func readRunInfo(dir string) (*runInfo, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, "run.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var info runInfo
+
+	if err := json.Unmarshal(content, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// copyFile copies the file in source to target.
+func copyFile(source, target string) error {
+	input, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+
+	defer input.Close()
+
+	output, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(output, input); err != nil {
+		output.Close()
+		return err
+	}
+
+	return output.Close()
+}
+
+// saveRun stores the dump of a model checker run (or the witness file of
+// an invariant check) and its input data in the history, returning the
+// identifier of the new run.
+func (s *WebUi) saveRun(input inputData, dumpfile string, stats maude.Stats) (string, error) {
+	var endTime = time.Now()
+	var id = endTime.Format(runIdFormat)
+
+	if err := os.MkdirAll(filepath.Join(s.DataDir, "runs"), 0755); err != nil {
+		return "", err
+	}
+
+	// Several runs may finish in the same second
+	var dir = s.runDir(id)
+
+	for i := 1; ; i++ {
+		if err := os.Mkdir(dir, 0755); err == nil {
+			break
+		} else if !os.IsExist(err) {
+			return "", err
+		}
+
+		id = endTime.Format(runIdFormat) + "-" + strconv.Itoa(i)
+		dir = s.runDir(id)
+	}
+
+	var info = runInfo{
+		Id:      id,
+		Tags:    make([]string, 0),
+		Input:   input,
+		EndTime: endTime,
+		Stats:   stats,
+	}
+
+	var target = filepath.Join(dir, "dump")
+
+	if input.Invariant {
+		witness, err := readWitness(dumpfile)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+
+		info.Holds = witness.Holds
+		info.NumberOfStates = witness.NumberOfStates
+		info.PathLength = len(witness.Path)
+		info.CycleLength = len(witness.Cycle)
+		target = filepath.Join(dir, witnessFile)
+
+	} else {
+		dump, err := smcdump.Read(dumpfile)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+
+		info.Holds = dump.PropertyHolds()
+		info.NumberOfStates = dump.NumberOfStates()
+		info.PathLength = len(dump.Path())
+		info.CycleLength = len(dump.Cycle())
+		dump.Close()
+	}
+
+	if err := copyFile(dumpfile, target); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	// The provenance of the dump is kept with it, if available
+	var provenance = smcdump.ProvenancePath(dumpfile)
+
+	if _, err := os.Stat(provenance); err == nil {
+		if err := copyFile(provenance, smcdump.ProvenancePath(target)); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+
+	if err := writeRunInfo(dir, &info); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	return id, nil
+}
+
+// listRuns lists all runs in the history, the most recent first.
+func (s *WebUi) listRuns() []runInfo {
+	var runs = make([]runInfo, 0)
+
+	if s.DataDir == "" {
+		return runs
+	}
+
+	entries, _ := ioutil.ReadDir(filepath.Join(s.DataDir, "runs"))
+
+	for _, entry := range entries {
+		if dir := s.runDir(entry.Name()); dir != "" && entry.IsDir() {
+			if info, err := readRunInfo(dir); err == nil {
+				info.Id = entry.Name()
+				runs = append(runs, *info)
+			}
+		}
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return runs[i].EndTime.After(runs[j].EndTime)
+	})
+
+	return runs
+}
+
+func (s *WebUi) handleHistory(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(writer).Encode(struct {
+		Enabled bool      `json:"enabled"`
+		Runs    []runInfo `json:"runs"`
+	}{s.DataDir != "", s.listRuns()})
+}
+
+// handleUpdateRun renames and retags a run.
+func (s *WebUi) handleUpdateRun(writer http.ResponseWriter, request *http.Request) {
+	var dir = s.runDir(request.FormValue("id"))
+
+	if dir == "" {
+		http.Error(writer, "Bad request", 400)
+		return
+	}
+
+	info, err := readRunInfo(dir)
+	if err != nil {
+		http.Error(writer, "Not found", 404)
+		return
+	}
+
+	info.Name = strings.TrimSpace(request.FormValue("name"))
+	info.Tags = removeEmptyString(strings.FieldsFunc(request.FormValue("tags"), func(r rune) bool {
+		return r == ',' || r == ' '
+	}))
+
+	if err := writeRunInfo(dir, info); err != nil {
+		http.Error(writer, "Internal server error", 500)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(info)
+}
+
+func (s *WebUi) handleDeleteRun(writer http.ResponseWriter, request *http.Request) {
+	var id = request.FormValue("id")
+	var dir = s.runDir(id)
+
+	if dir == "" {
+		http.Error(writer, "Bad request", 400)
+		return
+	}
+
+	// If the run is the pending result, the session returns to the
+	// selection screen
+	s.sessions.mutex.Lock()
+	if s.sessions.resultfile == "run:"+id && s.sessions.status == completed {
+		s.sessions.status = blank
+	}
+	s.sessions.mutex.Unlock()
+
+	if err := os.RemoveAll(dir); err != nil {
+		http.Error(writer, "Internal server error", 500)
+		return
+	}
+
+	http.Error(writer, "Ok", 200)
+}
+
+// handleRerun loads again the source file of a run and calls the model
+// checker with the same input data.
+func (s *WebUi) handleRerun(writer http.ResponseWriter, request *http.Request) {
+	var dir = s.runDir(request.FormValue("id"))
+
+	if dir == "" {
+		http.Error(writer, "Bad request", 400)
+		return
+	}
+
+	info, err := readRunInfo(dir)
+	if err != nil {
+		http.Error(writer, "Not found", 404)
+		return
+	}
+
+	s.sessions.mutex.Lock()
+	var status = s.sessions.status
+	s.sessions.mutex.Unlock()
+
+	if status == waitingAnswer {
+		http.Error(writer, "The model checker is already running", 409)
+		return
+	}
+
+	var hostpath = s.translatePath(info.Input.File)
+
+	if hostpath == "" {
+		http.Error(writer, "The source file of the run is no longer available", 404)
+		return
+	} else if _, err := os.Stat(hostpath); err != nil {
+		http.Error(writer, "The source file of the run is no longer available", 404)
+		return
+	}
+
+	var ctx = request.Context()
+
+	if _, _, err := s.loadSource(ctx, hostpath, false); err != nil {
+		s.reportMaudeError(writer, err)
+		return
+	}
+
+	s.setFileLoaded(info.Input.File)
+
+	var result modelCheckResult
+
+	if info.Input.Invariant {
+		result, err = s.startInvariant(ctx, info.Input)
+	} else {
+		result, err = s.startModelcheck(ctx, info.Input)
+	}
+
+	if err != nil {
+		s.reportMaudeError(writer, err)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(result)
+}
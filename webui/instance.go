@@ -0,0 +1,100 @@
+package webui
+
+import (
+	"context"
+	"github.com/ningit/smcview/maude"
+	"github.com/ningit/smcview/maude/term"
+	"strings"
+)
+
+// Name of the wrapper module that instantiates a parameterized module
+const instanceModule = "%SMCVIEW-INSTANCE"
+
+// instanceError is returned when a parameterized module cannot be
+// instantiated with the given views.
+type instanceError struct {
+	// Messages printed by Maude when trying to instantiate it
+	Messages []maude.Message
+}
+
+func (e *instanceError) Error() string {
+	var text = "The module cannot be instantiated with the given views"
+
+	for _, message := range e.Messages {
+		text += "\n" + message.Text
+	}
+
+	return text
+}
+
+// isInstanceError tells whether the error is an instanceError.
+func isInstanceError(err error) bool {
+	_, ok := err.(*instanceError)
+	return ok
+}
+
+// splitInstance splits a module expression like MOD{View1, View2} into the
+// module name and the views (nil if the module is not instantiated).
+func splitInstance(module string) (string, []string) {
+	var brace = strings.IndexByte(module, '{')
+
+	if brace < 0 || !strings.HasSuffix(module, "}") {
+		return module, nil
+	}
+
+	var views = strings.Split(module[brace+1:len(module)-1], ",")
+
+	for i, view := range views {
+		views[i] = strings.TrimSpace(view)
+	}
+
+	return strings.TrimSpace(module[:brace]), views
+}
+
+// resolveModule returns the name of a module where commands can be executed
+// for the given module expression. Instances of parameterized modules like
+// MOD{View} are generated as a wrapper module importing them.
+func resolveModule(ctx context.Context, maudec *maude.Client, module string) (string, error) {
+	name, views := splitInstance(module)
+
+	if views == nil {
+		return module, nil
+	}
+
+	var instance = name + "{" + strings.Join(views, ", ") + "}"
+
+	// If the instantiation fails, the wrapper is not (re)defined
+	messages, ok, err := maudec.DeclareWrapper(ctx, instanceModule,
+		[]term.Import{{Mode: "protecting", Module: instance}}, "")
+
+	if err != nil {
+		return "", err
+	} else if !ok {
+		return "", &instanceError{messages}
+	}
+
+	return instanceModule, nil
+}
+
+// viewsFor returns the names of the views that can instantiate each of the
+// given parameter theories.
+func viewsFor(ctx context.Context, maudec *maude.Client, theories []string) ([][]string, error) {
+	views, err := maudec.Views(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates = make([][]string, len(theories))
+
+	for i, theory := range theories {
+		candidates[i] = make([]string, 0)
+
+		for _, view := range views {
+			if view.From == theory {
+				candidates[i] = append(candidates[i], view.Name)
+			}
+		}
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,196 @@
+package webui
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/ningit/smcview/maude"
+	"github.com/ningit/smcview/util"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Name of the file in the temporary directory where the result of the last
+// invariant check is written
+const witnessFile = "witness.json"
+
+// handleInvariant checks that the atomic proposition in the formula field
+// holds in every state reachable from the initial term (under the strategy,
+// if given). Like the model checker, it runs in the background.
+func (s *WebUi) handleInvariant(writer http.ResponseWriter, request *http.Request) {
+	var input = inputData{
+		File:        s.loadedFile(),
+		Module:      request.FormValue("mod"),
+		InitialTerm: request.FormValue("initial"),
+		LtlFormula:  request.FormValue("formula"),
+		Strategy:    request.FormValue("strategy"),
+		Invariant:   true,
+	}
+
+	// Some parameters must be non-empty
+	if input.Module == "" || input.InitialTerm == "" || input.LtlFormula == "" {
+		http.Error(writer, "Bad request", 400)
+		return
+	}
+
+	result, err := s.startInvariant(request.Context(), input)
+	if err != nil {
+		s.reportMaudeError(writer, err)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(result)
+}
+
+// startInvariant checks the input of the invariant checker and, if correct,
+// starts it in the background.
+func (s *WebUi) startInvariant(ctx context.Context, input inputData) (modelCheckResult, error) {
+	var pool = s.currentPool()
+
+	if pool == nil {
+		return modelCheckResult{}, maude.ErrNotRunning
+	}
+
+	maudec, err := pool.Get(ctx)
+	if err != nil {
+		return modelCheckResult{}, err
+	}
+
+	result, module, err := checkInvariantInput(ctx, maudec, input)
+
+	if err != nil || result.Status != 0 {
+		pool.Put(maudec, err)
+		return result, err
+	}
+
+	var hostpath = filepath.Join(s.tempDir, witnessFile)
+	var provenance = s.newProvenance(input, hostpath)
+
+	s.startJob(pool, maudec, input, "tmp:"+witnessFile,
+		func(ctx context.Context, maudec *maude.Client) (maude.Stats, string, error) {
+			result, err := maudec.CheckInvariant(ctx, module, input.InitialTerm,
+				input.LtlFormula, input.Strategy, 0)
+
+			if err != nil {
+				return result.Stats, "", err
+			}
+
+			if err := writeWitness(hostpath, input, result); err != nil {
+				return result.Stats, "", err
+			}
+
+			writeProvenance(hostpath, provenance, result.Stats)
+
+			return result.Stats, hostpath, nil
+		})
+
+	return modelCheckResult{0, -1, nil}, nil
+}
+
+// checkInvariantInput checks that the initial term, the strategy (if any)
+// and the atomic proposition to be checked are correct, and returns the
+// module where the invariant is checked.
+func checkInvariantInput(ctx context.Context, maudec *maude.Client, input inputData) (modelCheckResult, string, error) {
+	// Instances of parameterized modules are generated
+	module, err := resolveModule(ctx, maudec, input.Module)
+	if err != nil {
+		return modelCheckResult{}, "", err
+	}
+
+	if err := maudec.Select(ctx, module); err != nil {
+		return modelCheckResult{}, "", err
+	}
+
+	if input.Strategy == "" {
+		if parse, err := maudec.Parse(ctx, input.InitialTerm, "State"); err != nil {
+			return modelCheckResult{}, "", err
+		} else if parse.Type != maude.Ok {
+			return modelCheckResult{1, parse.Pos, parse.Messages}, "", nil
+		}
+	} else {
+		if result, _, err := checkModelInput(ctx, maudec, input.InitialTerm, input.Strategy, nil); err != nil || result.Status != 0 {
+			return result, "", err
+		}
+	}
+
+	// Only atomic propositions can be checked with the satisfaction operator
+	if parse, err := maudec.Parse(ctx, input.LtlFormula, "Prop"); err != nil {
+		return modelCheckResult{}, "", err
+	} else if parse.Type != maude.Ok {
+		return modelCheckResult{2, parse.Pos, parse.Messages}, "", nil
+	}
+
+	return modelCheckResult{0, -1, nil}, module, nil
+}
+
+// writeWitness writes the result of an invariant check as the data for the
+// result view, where the witness path is shown like a counterexample whose
+// cycle is the state where the invariant fails.
+func writeWitness(hostpath string, input inputData, result maude.InvariantResult) error {
+	var resultdata = resultData{
+		Initial:        input.InitialTerm,
+		Formula:        input.LtlFormula,
+		Invariant:      true,
+		NumberOfStates: result.Stats.States,
+		Holds:          result.Holds,
+		Stats:          result.Stats,
+		Path:           make([]int32, 0),
+		Cycle:          make([]int32, 0),
+		States:         make(map[int32]stateData),
+	}
+
+	for i, step := range result.Witness {
+		var nr = int32(i)
+		var last = i+1 == len(result.Witness)
+		var transitions = make([]transitionData, 0, 1)
+
+		if !last {
+			var label = step.Label
+
+			if label == "" {
+				label = step.Rule
+			}
+
+			transitions = append(transitions, transitionData{nr + 1, label, 1})
+			resultdata.Path = append(resultdata.Path, nr)
+		} else {
+			resultdata.Cycle = append(resultdata.Cycle, nr)
+		}
+
+		resultdata.States[nr] = stateData{
+			Solution:    last,
+			Term:        util.CleanString(step.State),
+			Strategy:    input.Strategy,
+			Transitions: transitions,
+		}
+	}
+
+	content, err := json.Marshal(resultdata)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(hostpath, content, 0644)
+}
+
+// readWitness reads the result of an invariant check written by writeWitness.
+func readWitness(hostpath string) (*resultData, error) {
+	if filepath.Base(hostpath) != witnessFile {
+		return nil, os.ErrNotExist
+	}
+
+	content, err := ioutil.ReadFile(hostpath)
+	if err != nil {
+		return nil, err
+	}
+
+	var resultdata resultData
+
+	if err := json.Unmarshal(content, &resultdata); err != nil {
+		return nil, err
+	}
+
+	return &resultdata, nil
+}
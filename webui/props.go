@@ -0,0 +1,85 @@
+package webui
+
+import (
+	"context"
+	"github.com/ningit/smcview/maude"
+	"time"
+)
+
+// Maximum time to evaluate the atomic propositions in Maude
+const propsTimeout = 30 * time.Second
+
+// propData is the value of an atomic proposition in a state.
+type propData struct {
+	Name  string `json:"name"`
+	// Holds is nil if the value is unknown
+	Holds *bool  `json:"holds"`
+}
+
+// propTable is the truth table of the atomic propositions of the formula in
+// the states of the counterexample.
+type propTable struct {
+	// States are those of the path followed by those of the cycle
+	States     []int32
+	// CycleStart is the position of the first state of the cycle
+	CycleStart int
+	Rows       []propRow
+}
+
+type propRow struct {
+	Name   string
+	Values []maude.PropValue
+}
+
+// evaluateProps evaluates the atomic propositions of the formula in the
+// states of the counterexample, if the module where the dump was generated
+// is loaded, and fills the truth table of the result.
+func (s *WebUi) evaluateProps(ctx context.Context, dumpfile string, resultdata *resultData) {
+	if len(resultdata.Cycle) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, propsTimeout)
+	defer cancel()
+
+	var sequence, terms = counterexampleTerms(resultdata)
+	var table *maude.PropTable
+
+	s.withDumpModule(ctx, dumpfile, func(maudec *maude.Client, module string) (err error) {
+		table, err = maudec.EvalProps(ctx, module, resultdata.Formula, terms)
+		return err
+	})
+
+	if table == nil || len(table.Props) == 0 {
+		return
+	}
+
+	var ptable = &propTable{
+		States:     sequence,
+		CycleStart: len(resultdata.Path),
+		Rows:       make([]propRow, len(table.Props)),
+	}
+
+	for j, name := range table.Props {
+		ptable.Rows[j] = propRow{name, make([]maude.PropValue, len(sequence))}
+	}
+
+	for i, nr := range sequence {
+		var state = resultdata.States[nr]
+		state.Props = make([]propData, len(table.Props))
+
+		for j, value := range table.Values[i] {
+			ptable.Rows[j].Values[i] = value
+			state.Props[j].Name = table.Props[j]
+
+			if value != maude.PropUnknown {
+				var holds = value == maude.PropTrue
+				state.Props[j].Holds = &holds
+			}
+		}
+
+		resultdata.States[nr] = state
+	}
+
+	resultdata.Props = ptable
+}
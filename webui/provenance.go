@@ -0,0 +1,74 @@
+package webui
+
+import (
+	"github.com/ningit/smcview/maude"
+	"github.com/ningit/smcview/smcdump"
+	"log"
+	"os"
+	"time"
+)
+
+// provenanceData is the provenance of a result as shown in the result view.
+type provenanceData struct {
+	*smcdump.Provenance
+	// SourceChanged tells whether the source file has changed since the
+	// result was generated
+	SourceChanged bool
+}
+
+// newProvenance describes the origin of a result generated from the given
+// input in the source file loaded in the session. The source is recorded
+// by its web URL, since the provenance is shown to the clients. The
+// provenance of the previous result in the same place is removed.
+func (s *WebUi) newProvenance(input inputData, dumpfile string) *smcdump.Provenance {
+	os.Remove(smcdump.ProvenancePath(dumpfile))
+
+	s.sessions.mutex.Lock()
+	var source = s.sessions.source
+	s.sessions.mutex.Unlock()
+
+	var provenance = &smcdump.Provenance{
+		Source:       input.File,
+		Module:       input.Module,
+		InitialTerm:  input.InitialTerm,
+		LtlFormula:   input.LtlFormula,
+		Strategy:     input.Strategy,
+		Opaques:      input.Opaques,
+		MaudeVersion: s.maudeVersion,
+		StartTime:    time.Now(),
+	}
+
+	provenance.SourceHash, _ = smcdump.HashFile(source)
+
+	return provenance
+}
+
+// writeProvenance completes the provenance of a result with the statistics
+// of the run and writes it next to the result.
+func writeProvenance(dumpfile string, provenance *smcdump.Provenance, stats maude.Stats) {
+	provenance.EndTime = time.Now()
+
+	if stats.Available {
+		provenance.Rewrites = stats.Rewrites
+		provenance.CpuTime = stats.CpuTime
+		provenance.RealTime = stats.RealTime
+	}
+
+	if err := smcdump.WriteProvenance(dumpfile, provenance); err != nil {
+		log.Print("cannot write the provenance of the result: ", err)
+	}
+}
+
+// readProvenance reads the provenance of the result in the given path, or
+// returns nil if it is not known.
+func (s *WebUi) readProvenance(hostpath string) *provenanceData {
+	provenance, err := smcdump.ReadProvenance(hostpath)
+	if err != nil {
+		return nil
+	}
+
+	// The source is resolved as any other web URL
+	var source = s.translatePath(provenance.Source)
+
+	return &provenanceData{provenance, provenance.SourceChanged(source)}
+}
@@ -0,0 +1,164 @@
+package webui
+
+import (
+	"encoding/json"
+	"github.com/ningit/smcview/smcdump"
+	"github.com/ningit/smcview/util"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// simplifierInfo describes the simplifier applied in the result view.
+type simplifierInfo struct {
+	Op     string `json:"op"`
+	File   string `json:"file"`
+	Active bool   `json:"active"`
+}
+
+// currentSimplifier returns the simplifier for the terms of the result view.
+func (s *WebUi) currentSimplifier() util.TermSimplifier {
+	s.simplifierMutex.Lock()
+	defer s.simplifierMutex.Unlock()
+
+	return s.simplifier
+}
+
+func (s *WebUi) handleSimplifier(writer http.ResponseWriter, request *http.Request) {
+	s.simplifierMutex.Lock()
+	var info = simplifierInfo{s.simplifierOp, s.simplifierFile, s.simplifierOp != ""}
+	s.simplifierMutex.Unlock()
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(info)
+}
+
+// handleSetSimplifier replaces the simplifier by one that reduces the given
+// operator in the given Maude file, followed by the rest of the chain given
+// in the command line. An empty operator disables the Maude simplifier, and
+// an empty file keeps the one of the command line.
+func (s *WebUi) handleSetSimplifier(writer http.ResponseWriter, request *http.Request) {
+	var (
+		op   = request.FormValue("op")
+		file = request.FormValue("file")
+	)
+
+	var specs = s.simplifierSpecs
+
+	// The leading Maude simplifier is the one the user can replace
+	if leadingOp(specs) != "" {
+		specs = specs[1:]
+	}
+
+	if op != "" {
+		var spec = util.SimplifierSpec{Type: "maude", Op: op}
+
+		if file == "" && leadingOp(s.simplifierSpecs) != "" {
+			spec.File, spec.Module = s.simplifierSpecs[0].File, s.simplifierSpecs[0].Module
+		} else if spec.File = s.editableSource(file); spec.File == "" {
+			http.Error(writer, "Bad request", 400)
+			return
+		}
+
+		// The timeout of the command line is kept
+		if leadingOp(s.simplifierSpecs) != "" {
+			spec.Timeout = s.simplifierSpecs[0].Timeout
+		}
+
+		specs = append([]util.SimplifierSpec{spec}, specs...)
+	} else {
+		file = ""
+	}
+
+	var simplifier = util.CreateDummySimplifier()
+
+	if len(specs) > 0 {
+		var err error
+
+		if simplifier, err = util.NewSimplifierChain(specs, s.maudePath); err != nil {
+			http.Error(writer, "Cannot start the simplifier: "+err.Error(), 500)
+			return
+		}
+	}
+
+	s.simplifierMutex.Lock()
+	var old = s.simplifier
+	s.simplifier, s.simplifierOp, s.simplifierFile = simplifier, op, file
+	s.termCache, s.termCacheKey = nil, ""
+	s.simplifierMutex.Unlock()
+
+	// The old simplifier may still be in use by a request
+	go old.Close()
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(simplifierInfo{op, file, op != ""})
+}
+
+// leadingOp returns the operator of the first simplifier of the chain if it
+// is a Maude simplifier, or the empty string otherwise.
+func leadingOp(specs []util.SimplifierSpec) string {
+	if len(specs) > 0 && specs[0].Type == "maude" {
+		return specs[0].Op
+	}
+
+	return ""
+}
+
+// termCacheFor returns the cache of simplified terms for the dump in the
+// given path, which is kept while the dump and the simplifier do not change.
+func (s *WebUi) termCacheFor(hostpath string) *util.TermCache {
+	var key = hostpath
+
+	if stat, err := os.Stat(hostpath); err == nil {
+		key += ":" + strconv.FormatInt(stat.ModTime().UnixNano(), 10)
+	}
+
+	s.simplifierMutex.Lock()
+	defer s.simplifierMutex.Unlock()
+
+	if s.termCache == nil || s.termCacheKey != key {
+		s.termCache, s.termCacheKey = util.NewTermCache(s.simplifier), key
+	}
+
+	return s.termCache
+}
+
+// stateTerms returns the simplified term of a state and the original one
+// if they differ (or the empty string otherwise).
+func stateTerms(simplified, term string) (string, string) {
+	simplified, term = util.CleanString(simplified), util.CleanString(term)
+
+	if simplified == term {
+		return term, ""
+	}
+
+	return simplified, term
+}
+
+// prefetchStates simplifies all together the terms of the given states.
+func prefetchStates(terms *util.TermCache, states []int32, dump smcdump.SmcDump) {
+	var indices = make([]int32, 0, len(states))
+
+	for _, stateNr := range states {
+		indices = append(indices, dump.State(stateNr).Term)
+	}
+
+	terms.Prefetch(dump, indices)
+}
+
+// simplifyStates simplifies the terms of the states of a result.
+func simplifyStates(stateMap map[int32]stateData, simplifier util.TermSimplifier) {
+	var numbers = make([]int32, 0, len(stateMap))
+	var terms = make([]string, 0, len(stateMap))
+
+	for nr, state := range stateMap {
+		numbers = append(numbers, nr)
+		terms = append(terms, state.Term)
+	}
+
+	for i, simplified := range simplifier.SimplifyAll(terms) {
+		var state = stateMap[numbers[i]]
+		state.Term, state.RawTerm = stateTerms(simplified, terms[i])
+		stateMap[numbers[i]] = state
+	}
+}
@@ -0,0 +1,170 @@
+package webui
+
+import (
+	"encoding/json"
+	"github.com/ningit/smcview/maude"
+	"net/http"
+	"strconv"
+)
+
+// simulateResult is the result of a simulation command passed as JSON to
+// the browser. Rewriting commands produce a single solution.
+type simulateResult struct {
+	Ok        bool             `json:"ok"`
+	Solutions []maude.Solution `json:"solutions"`
+	Complete  bool             `json:"complete"`
+	Stats     maude.Stats      `json:"stats"`
+	// Messages printed by Maude while executing the command
+	Messages  []maude.Message  `json:"messages"`
+}
+
+// boundValue parses an optional non-negative bound of a command, where the
+// empty string means unbounded (zero).
+func boundValue(value string) (int, bool) {
+	if value == "" {
+		return 0, true
+	}
+
+	bound, err := strconv.Atoi(value)
+
+	return bound, err == nil && bound >= 0
+}
+
+// handleSimulate executes a rewriting or search command on the initial term
+// in the selected module, so that the system can be explored before model
+// checking it. The command is interrupted if the request is cancelled.
+func (s *WebUi) handleSimulate(writer http.ResponseWriter, request *http.Request) {
+	var (
+		command  = request.FormValue("command")
+		module   = request.FormValue("mod")
+		initial  = request.FormValue("initial")
+		strategy = request.FormValue("strategy")
+		arrow    = maude.SearchArrow(request.FormValue("arrow"))
+	)
+
+	bound, boundOk := boundValue(request.FormValue("bound"))
+	depth, depthOk := boundValue(request.FormValue("depth"))
+
+	if module == "" || initial == "" || !boundOk || !depthOk {
+		http.Error(writer, "Bad request", 400)
+		return
+	}
+
+	var result = simulateResult{Solutions: make([]maude.Solution, 0)}
+	var ctx = request.Context()
+
+	// Rewriting commands are converted to a single solution
+	var rewriteResult = func(rwresult maude.ReduceResult) {
+		result.Ok = rwresult.Ok
+		result.Complete = rwresult.Ok
+		result.Stats = rwresult.Stats
+
+		if rwresult.Ok {
+			result.Solutions = append(result.Solutions, maude.Solution{
+				Number:       1,
+				State:        -1,
+				Term:         rwresult.Term,
+				Type:         rwresult.Type,
+				Substitution: make([]maude.Binding, 0),
+				Stats:        rwresult.Stats,
+			})
+		}
+	}
+
+	// Search results are passed as they are
+	var searchResult = func(sresult maude.SearchResult) {
+		result = simulateResult{sresult.Ok, sresult.Solutions, sresult.Complete, sresult.Stats, nil}
+	}
+
+	// The command runs in the module where the input module expression
+	// is resolved
+	var run func(maudec *maude.Client, module string) error
+
+	switch command {
+		case "rew" :
+			run = func(maudec *maude.Client, module string) error {
+				rwresult, err := maudec.Rewrite(ctx, module, initial, bound)
+				rewriteResult(rwresult)
+				return err
+			}
+		case "frew" :
+			run = func(maudec *maude.Client, module string) error {
+				rwresult, err := maudec.Frewrite(ctx, module, initial, bound, depth)
+				rewriteResult(rwresult)
+				return err
+			}
+		case "search" :
+			switch arrow {
+				case maude.OneStep, maude.OneOrMoreSteps, maude.AnySteps, maude.NormalForm:
+				default:
+					http.Error(writer, "Bad request", 400)
+					return
+			}
+
+			var pattern = request.FormValue("pattern")
+			var condition = request.FormValue("condition")
+
+			if pattern == "" {
+				http.Error(writer, "Bad request", 400)
+				return
+			}
+
+			run = func(maudec *maude.Client, module string) error {
+				sresult, err := maudec.Search(ctx, maude.SearchQuery{
+					Module:       module,
+					Initial:      initial,
+					Arrow:        arrow,
+					Pattern:      pattern,
+					Condition:    condition,
+					MaxSolutions: bound,
+					MaxDepth:     depth,
+				})
+				searchResult(sresult)
+				return err
+			}
+		case "srew", "dsrew" :
+			if strategy == "" {
+				http.Error(writer, "Bad request", 400)
+				return
+			}
+
+			run = func(maudec *maude.Client, module string) error {
+				var sresult maude.SearchResult
+				var err error
+
+				if command == "srew" {
+					sresult, err = maudec.Srewrite(ctx, module, initial, strategy, bound)
+				} else {
+					sresult, err = maudec.Dsrewrite(ctx, module, initial, strategy, bound)
+				}
+
+				searchResult(sresult)
+				return err
+			}
+		default :
+			http.Error(writer, "Bad request", 400)
+			return
+	}
+
+	err := s.withInterpreter(ctx, func(maudec *maude.Client) error {
+		module, err := resolveModule(ctx, maudec, module)
+		if err != nil {
+			return err
+		}
+
+		// Invalid inputs are explained by Maude in its standard error
+		result.Messages, err = maudec.Capture(ctx, func(maudec *maude.Client) error {
+			return run(maudec, module)
+		})
+
+		return err
+	})
+
+	if err != nil {
+		s.reportMaudeError(writer, err)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(result)
+}
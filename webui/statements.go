@@ -0,0 +1,58 @@
+package webui
+
+import (
+	"encoding/json"
+	"github.com/ningit/smcview/maude"
+	"github.com/ningit/smcview/smcdump"
+	"net/http"
+	"strconv"
+)
+
+// transitionSource is the answer to the transition question.
+type transitionSource struct {
+	// Available tells whether the module of the dump is loaded
+	Available  bool     `json:"available"`
+	Statements []string `json:"statements"`
+}
+
+// handleTransitionSource finds the rules (or the definitions of the opaque
+// strategy) behind a transition label of the dump being viewed, in the
+// module where it was generated.
+func (s *WebUi) handleTransitionSource(writer http.ResponseWriter, request *http.Request) {
+	var label = request.FormValue("label")
+	trType, err := strconv.Atoi(request.FormValue("type"))
+
+	if err != nil || label == "" {
+		http.Error(writer, "Bad request", 400)
+		return
+	}
+
+	var answer = transitionSource{Statements: make([]string, 0)}
+	var ctx = request.Context()
+	var maudeErr error
+
+	s.withDumpModule(ctx, s.currentDumpfile(), func(maudec *maude.Client, module string) error {
+		var opaque = smcdump.TransitionType(trType) == smcdump.Opaque
+		var statements []string
+
+		if statements, maudeErr = maudec.TransitionSource(ctx, module, label, opaque); maudeErr != nil {
+			return maudeErr
+		}
+
+		// The statements are always a list in the answer, even if empty
+		if statements != nil {
+			answer.Statements = statements
+		}
+
+		answer.Available = true
+		return nil
+	})
+
+	if maudeErr != nil {
+		s.reportMaudeError(writer, maudeErr)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(answer)
+}
@@ -0,0 +1,339 @@
+package webui
+
+import (
+	"context"
+	"github.com/ningit/smcview/maude"
+	"github.com/ningit/smcview/maude/term"
+	"strings"
+	"time"
+)
+
+// Associative lists with more arguments than maxListArgs are shown with
+// their first listHead and last listTail arguments only (until expanded)
+const (
+	maxListArgs = 10
+	listHead    = 4
+	listTail    = 2
+)
+
+// Maximum time to obtain the structure of the terms from Maude
+const structureTimeout = 10 * time.Second
+
+// termView is the structured rendering of a term or strategy for the result
+// view. The text of the node is split in parts around its arguments, so
+// that Parts[i] precedes Args[i] and the last part follows the last one.
+type termView struct {
+	Parts   [][]termToken `json:"parts"`
+	Args    []*termView   `json:"args,omitempty"`
+	// Elided arguments of long associative lists are hidden initially
+	Elided  bool          `json:"elided,omitempty"`
+	// Changed marks the subterms that differ from the predecessor state
+	Changed bool          `json:"changed,omitempty"`
+	// Operator name to compare the views
+	op      string
+}
+
+// leafView is the view of a constant or any unstructured text.
+func leafView(text string) *termView {
+	return &termView{Parts: [][]termToken{tokenizeTerm(text)}, op: text}
+}
+
+// viewFromTerm builds the view of a term from its metarepresentation, which
+// is printed back in mixfix syntax.
+func viewFromTerm(t *term.Term) *termView {
+	if t.Kind != term.Application {
+		return leafView(t.Name)
+	}
+
+	var view = &termView{Args: make([]*termView, len(t.Args)), op: t.Name}
+
+	for i, arg := range t.Args {
+		view.Args[i] = viewFromTerm(arg)
+	}
+
+	var parts = mixfixParts(t.Name, len(t.Args))
+
+	// Arguments of operators without delimiters at their sides are
+	// parenthesized if they have no delimiters either
+	if parts[0] == "" || parts[len(parts)-1] == "" {
+		for i, arg := range t.Args {
+			if arg.Kind == term.Application && isOpenMixfix(arg.Name) {
+				parts[i] += "("
+				parts[i+1] = ")" + parts[i+1]
+			}
+		}
+	}
+
+	view.Parts = make([][]termToken, len(parts))
+
+	for i, part := range parts {
+		view.Parts[i] = tokenizeTerm(part)
+	}
+
+	// The middle of long associative lists is elided
+	if len(t.Args) > maxListArgs && strings.Count(t.Name, "_") == 2 {
+		for _, arg := range view.Args[listHead:len(view.Args)-listTail] {
+			arg.Elided = true
+		}
+	}
+
+	return view
+}
+
+// mixfixParts splits the name of an operator into the text around its
+// arguments. Operators whose underscores do not match their arguments are
+// printed in prefix form, and flattened associative operators repeat their
+// middle part.
+func mixfixParts(name string, nargs int) []string {
+	var pieces = strings.Split(name, "_")
+	var parts = make([]string, nargs+1)
+
+	// Iterated operators like s_^3 are printed like s^3(0)
+	if caret := strings.LastIndexByte(name, '^'); caret > 0 && nargs == 1 &&
+		strings.Trim(name[caret+1:], "0123456789") == "" {
+		return []string{strings.Replace(name[:caret], "_", "", -1) + name[caret:] + "(", ")"}
+	}
+
+	switch {
+		case len(pieces) == nargs+1 :
+			copy(parts, pieces)
+		case len(pieces) == 3 && nargs > 2 :
+			parts[0], parts[nargs] = pieces[0], pieces[2]
+
+			for i := 1; i < nargs; i++ {
+				parts[i] = pieces[1]
+			}
+		default :
+			parts[0], parts[nargs] = name+"(", ")"
+
+			for i := 1; i < nargs; i++ {
+				parts[i] = ", "
+			}
+
+			return parts
+	}
+
+	// Tokens are separated by spaces as Maude does
+	for i, part := range parts {
+		switch {
+			case part == "" && i > 0 && i < nargs : parts[i] = " "
+			case part == ""                       :
+			case i == 0                           : parts[i] = part + " "
+			case i == nargs                       : parts[i] = " " + part
+			default                               : parts[i] = " " + part + " "
+		}
+	}
+
+	return parts
+}
+
+// isOpenMixfix tells whether a mixfix operator starts or ends with an
+// argument, so that it may need parentheses.
+func isOpenMixfix(name string) bool {
+	return strings.Count(name, "_") > 0 && (name[0] == '_' || name[len(name)-1] == '_')
+}
+
+// viewFromTokens builds a view of a term or strategy from its tokens alone,
+// where the groups between brackets are the subterms.
+func viewFromTokens(tokens []termToken) *termView {
+	var root = &termView{Parts: [][]termToken{{}}}
+	var stack = []*termView{root}
+
+	for _, token := range tokens {
+		var top = stack[len(stack)-1]
+
+		switch {
+			case token.Class == "punct" && strings.Contains("([{", token.Text) :
+				var group = &termView{Parts: [][]termToken{{token}}}
+				stack = append(stack, group)
+			case token.Class == "punct" && strings.Contains(")]}", token.Text) && len(stack) > 1 :
+				top.Parts[len(top.Parts)-1] = append(top.Parts[len(top.Parts)-1], token)
+				stack = stack[:len(stack)-1]
+				closeGroup(stack[len(stack)-1], top)
+			default :
+				top.Parts[len(top.Parts)-1] = append(top.Parts[len(top.Parts)-1], token)
+		}
+	}
+
+	// Unbalanced groups are closed at the end
+	for len(stack) > 1 {
+		var top = stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		closeGroup(stack[len(stack)-1], top)
+	}
+
+	return root
+}
+
+// closeGroup adds a finished group as an argument of its parent view.
+func closeGroup(parent, group *termView) {
+	for _, part := range group.Parts {
+		for _, token := range part {
+			group.op += token.Text
+		}
+	}
+
+	parent.Args = append(parent.Args, group)
+	parent.Parts = append(parent.Parts, []termToken{})
+}
+
+// markChanges marks the subterms of a view that differ from those of the
+// view of the predecessor state.
+func markChanges(view, prev *termView) {
+	if prev == nil {
+		return
+	}
+
+	if view.op != prev.op || len(view.Args) != len(prev.Args) || len(view.Parts) != len(prev.Parts) {
+		view.Changed = true
+		return
+	}
+
+	for i, part := range view.Parts {
+		if !sameTokens(part, prev.Parts[i]) {
+			view.Changed = true
+			return
+		}
+	}
+
+	for i, arg := range view.Args {
+		markChanges(arg, prev.Args[i])
+	}
+}
+
+// sameTokens tells whether two lists of tokens are equal.
+func sameTokens(left, right []termToken) bool {
+	if len(left) != len(right) {
+		return false
+	}
+
+	for i, token := range left {
+		if token != right[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// structureStates builds the views of the terms and strategies of the
+// states of a result. The structure of the terms is obtained from Maude if
+// the module of the dump is loaded, and the changes with respect to the
+// predecessor state in the counterexample are marked.
+func (s *WebUi) structureStates(ctx context.Context, dumpfile string, resultdata *resultData) {
+	var sequence, raws = counterexampleTerms(resultdata)
+	var structures = s.termStructures(ctx, dumpfile, raws)
+	var prev *stateData
+
+	for i, nr := range sequence {
+		var state = resultdata.States[nr]
+
+		// The structure from Maude is that of the raw term
+		var rawView *termView
+
+		if structures != nil && structures[i] != nil {
+			rawView = viewFromTerm(structures[i])
+		} else {
+			rawView = viewFromTokens(tokenizeTerm(raws[i]))
+		}
+
+		if state.RawTerm == "" {
+			state.View = rawView
+		} else {
+			state.View = viewFromTokens(tokenizeTerm(state.Term))
+			state.RawView = rawView
+		}
+
+		state.StratView = viewFromTokens(tokenizeTerm(state.Strategy))
+
+		if prev != nil {
+			markChanges(state.View, prev.View)
+			markChanges(state.StratView, prev.StratView)
+
+			if state.RawView != nil {
+				var prevRaw = prev.RawView
+
+				if prevRaw == nil {
+					prevRaw = prev.View
+				}
+
+				markChanges(state.RawView, prevRaw)
+			}
+		}
+
+		resultdata.States[nr] = state
+		prev = &state
+	}
+}
+
+// counterexampleTerms returns the states of the path followed by those of
+// the cycle, and their terms before simplification.
+func counterexampleTerms(resultdata *resultData) ([]int32, []string) {
+	var sequence = append(append([]int32(nil), resultdata.Path...), resultdata.Cycle...)
+	var raws = make([]string, len(sequence))
+
+	for i, nr := range sequence {
+		var state = resultdata.States[nr]
+
+		if raws[i] = state.RawTerm; raws[i] == "" {
+			raws[i] = state.Term
+		}
+	}
+
+	return sequence, raws
+}
+
+// termStructures obtains the metarepresentation of the given terms of the
+// dump from Maude, or nil if the module where the dump was generated is not
+// loaded in the current session.
+func (s *WebUi) termStructures(ctx context.Context, dumpfile string, terms []string) []*term.Term {
+	ctx, cancel := context.WithTimeout(ctx, structureTimeout)
+	defer cancel()
+
+	var structures []*term.Term
+
+	s.withDumpModule(ctx, dumpfile, func(maudec *maude.Client, module string) (err error) {
+		structures, err = maudec.UpTerms(ctx, module, terms)
+		return err
+	})
+
+	return structures
+}
+
+// withDumpModule calls fn with an interpreter of the session and the module
+// where the dump was generated, if it is loaded in the current session.
+func (s *WebUi) withDumpModule(ctx context.Context, dumpfile string, fn func(maudec *maude.Client, module string) error) {
+	var input inputData
+
+	s.sessions.mutex.Lock()
+	var pool, current, resultfile = s.sessions.pool, s.sessions.inputData, s.sessions.resultfile
+	s.sessions.mutex.Unlock()
+
+	// The input of the dump is known for the runs in the history and
+	// for the last result
+	if strings.HasPrefix(dumpfile, "run:") {
+		info, err := readRunInfo(s.runDir(dumpfile[4:]))
+		if err != nil {
+			return
+		}
+
+		input = info.Input
+	} else if dumpfile == resultfile {
+		input = current
+	} else {
+		return
+	}
+
+	if input.Module == "" || input.File != current.File {
+		return
+	}
+
+	withPool(ctx, pool, func(maudec *maude.Client) error {
+		module, err := resolveModule(ctx, maudec, input.Module)
+		if err != nil {
+			return err
+		}
+
+		return fn(maudec, module)
+	})
+}
@@ -0,0 +1,362 @@
+package webui
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"github.com/ningit/smcview/smcdump"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Default maximum size in bytes of an uploaded file (including the
+// uncompressed contents of source bundles).
+const defaultMaxUploadSize = 32 << 20
+
+var errUploadTooLarge = errors.New("upload too large")
+
+// newUploadDir creates a fresh directory for an upload inside the temporary
+// directory and returns both its host path and its web URL.
+func (s *WebUi) newUploadDir() (string, string, error) {
+	// Uploads may be received concurrently, so the name is chosen and the
+	// directory is created atomically
+	hostdir, err := ioutil.TempDir(s.tempDir, "upload")
+	if err != nil {
+		return "", "", err
+	}
+
+	return hostdir, "tmp:" + filepath.Base(hostdir), nil
+}
+
+// bundleKind returns the archive format of a source bundle judging by its
+// name, or the empty string if it is not a bundle.
+func bundleKind(name string) string {
+	var lower = strings.ToLower(name)
+
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar"
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tgz"
+	}
+
+	return ""
+}
+
+// cleanEntryName validates the name of an archive entry, which must be
+// relative and must not escape the extraction directory.
+func cleanEntryName(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+
+	if path.IsAbs(name) {
+		return "", false
+	}
+
+	name = path.Clean(name)
+
+	if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+
+	return name, true
+}
+
+// extractEntry copies an archive entry into the extraction directory,
+// decreasing the remaining byte budget.
+func extractEntry(hostdir, name string, reader io.Reader, budget *int64) error {
+	var target = filepath.Join(hostdir, filepath.FromSlash(name))
+
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	// One more byte is read to detect budget excesses
+	written, err := io.Copy(file, io.LimitReader(reader, *budget+1))
+	*budget -= written
+
+	if err == nil && *budget < 0 {
+		err = errUploadTooLarge
+	}
+
+	return err
+}
+
+// extractBundle extracts a zip or tar archive of source files in the given
+// directory. Only regular files are extracted, and the size of their
+// contents is limited by maxSize.
+func extractBundle(archive, kind, hostdir string, maxSize int64) error {
+	var budget = maxSize
+
+	if kind == "zip" {
+		zipReader, err := zip.OpenReader(archive)
+		if err != nil {
+			return err
+		}
+
+		defer zipReader.Close()
+
+		for _, entry := range zipReader.File {
+			if !entry.Mode().IsRegular() {
+				continue
+			}
+
+			name, ok := cleanEntryName(entry.Name)
+			if !ok {
+				return errors.New("bad entry name in archive")
+			}
+
+			content, err := entry.Open()
+			if err != nil {
+				return err
+			}
+
+			err = extractEntry(hostdir, name, content, &budget)
+			content.Close()
+
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	var reader io.Reader = file
+
+	if kind == "tgz" {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+
+		defer gzipReader.Close()
+		reader = gzipReader
+	}
+
+	var tarReader = tar.NewReader(reader)
+
+	for {
+		header, err := tarReader.Next()
+
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name, ok := cleanEntryName(header.Name)
+		if !ok {
+			return errors.New("bad entry name in archive")
+		}
+
+		if err := extractEntry(hostdir, name, tarReader, &budget); err != nil {
+			return err
+		}
+	}
+}
+
+// isMaudeSource tells whether the content of a file looks like a Maude
+// source, which is plain text without control characters other than
+// blanks. Binary files are rejected before they are loaded into Maude.
+func isMaudeSource(hostpath string) bool {
+	file, err := os.Open(hostpath)
+	if err != nil {
+		return false
+	}
+
+	defer file.Close()
+
+	var reader = bufio.NewReader(file)
+
+	for {
+		char, err := reader.ReadByte()
+
+		if err == io.EOF {
+			return true
+		} else if err != nil {
+			return false
+		}
+
+		if char < 0x20 && strings.IndexByte("\t\n\r\f", char) < 0 || char == 0x7f {
+			return false
+		}
+	}
+}
+
+// maudeFiles lists the Maude source files below a directory as
+// web URLs relative to the given base URL.
+func maudeFiles(hostdir, baseUrl string) []string {
+	var files = make([]string, 0)
+
+	filepath.Walk(hostdir, func(fpath string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && filepath.Ext(fpath) == ".maude" {
+			relpath, _ := filepath.Rel(hostdir, fpath)
+			files = append(files, baseUrl+"/"+filepath.ToSlash(relpath))
+		}
+		return nil
+	})
+
+	return files
+}
+
+// handleUpload receives Maude source files, bundles of them (as zip or tar
+// files) and model checker dumps from the browser. They are stored in the
+// temporary directory, and their URLs are returned to be used by the
+// normal loading flow.
+func (s *WebUi) handleUpload(writer http.ResponseWriter, request *http.Request) {
+	// Multipart overhead is small, but we allow some extra room for it
+	request.Body = http.MaxBytesReader(writer, request.Body, s.MaxUploadSize+4096)
+
+	if err := request.ParseMultipartForm(1 << 20); err != nil {
+		http.Error(writer, "Request entity too large or malformed", 413)
+		return
+	}
+
+	defer request.MultipartForm.RemoveAll()
+
+	var mode = request.FormValue("mode")
+
+	if mode != "source" && mode != "dump" && mode != "simplifier" {
+		http.Error(writer, "Bad request", 400)
+		return
+	}
+
+	upload, header, err := request.FormFile("file")
+	if err != nil {
+		http.Error(writer, "Bad request", 400)
+		return
+	}
+
+	defer upload.Close()
+
+	// The uploaded file name is only used for its base name and extension
+	var name = path.Base(strings.ReplaceAll(header.Filename, "\\", "/"))
+	var kind = bundleKind(name)
+
+	if name == "." || name == "/" || mode != "dump" && kind == "" && filepath.Ext(name) != ".maude" {
+		http.Error(writer, "Unsupported file type", 415)
+		return
+	}
+
+	hostdir, webdir, err := s.newUploadDir()
+	if err != nil {
+		http.Error(writer, "Internal server error", 500)
+		return
+	}
+
+	var hostpath = filepath.Join(hostdir, name)
+
+	file, err := os.Create(hostpath)
+	if err == nil {
+		_, err = io.Copy(file, upload)
+		file.Close()
+	}
+
+	if err != nil {
+		os.RemoveAll(hostdir)
+		http.Error(writer, "Internal server error", 500)
+		return
+	}
+
+	var files []string
+
+	switch {
+	case mode == "dump":
+		if !smcdump.HasSignature(hostpath) {
+			os.RemoveAll(hostdir)
+			http.Error(writer, "The given file is not a valid dump", 415)
+			return
+		}
+
+		files = []string{webdir + "/" + name}
+
+	case kind != "":
+		// The archive is extracted in a subdirectory and then removed
+		var extractdir = filepath.Join(hostdir, "src")
+		err = extractBundle(hostpath, kind, extractdir, s.MaxUploadSize)
+		os.Remove(hostpath)
+
+		if err != nil {
+			os.RemoveAll(hostdir)
+
+			if err == errUploadTooLarge {
+				http.Error(writer, "Request entity too large", 413)
+			} else {
+				http.Error(writer, "Bad archive file", 415)
+			}
+			return
+		}
+
+		files = maudeFiles(extractdir, webdir+"/src")
+
+		// All sources in the bundle must be valid
+		for _, url := range files {
+			if !isMaudeSource(s.tmpPath(url)) {
+				os.RemoveAll(hostdir)
+				http.Error(writer, "The archive contains files that are not Maude sources", 415)
+				return
+			}
+		}
+
+	default:
+		if !isMaudeSource(hostpath) {
+			os.RemoveAll(hostdir)
+			http.Error(writer, "The given file is not a Maude source", 415)
+			return
+		}
+
+		files = []string{webdir + "/" + name}
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+
+	json.NewEncoder(writer).Encode(struct {
+		Files []string `json:"files"`
+	}{files})
+}
+
+// tmpPath translates a URL of the form tmp:relative/path into a path inside
+// the temporary directory, or the empty string if it is malformed.
+func (s *WebUi) tmpPath(url string) string {
+	var relpath = url[4:]
+
+	if relpath == "" || strings.Contains(relpath, "\\") {
+		return ""
+	}
+
+	relpath = path.Clean("/" + relpath)
+
+	if relpath == "/" {
+		return ""
+	}
+
+	return filepath.Join(s.tempDir, filepath.FromSlash(relpath))
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"github.com/ningit/smcview/grapher"
 	"github.com/ningit/smcview/maude"
+	"github.com/ningit/smcview/maude/term"
 	"github.com/ningit/smcview/smcdump"
 	"github.com/ningit/smcview/util"
 	"github.com/shurcooL/httpfs/html/vfstemplate"
@@ -19,6 +20,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -34,33 +36,59 @@ const (
 )
 
 type inputData struct {
-	File        string
-	Module      string
-	InitialTerm string
-	LtlFormula  string
-	Strategy    string
-	Opaques     string
-	StartTime   time.Time
+	File        string    `json:"file"`
+	Module      string    `json:"module"`
+	InitialTerm string    `json:"initial"`
+	LtlFormula  string    `json:"formula"`
+	Strategy    string    `json:"strategy"`
+	Opaques     string    `json:"opaques"`
+	StartTime   time.Time `json:"startTime"`
+	// Whether the formula is an invariant instead of an LTL formula
+	Invariant   bool      `json:"invariant"`
 }
 
 type mcSession struct {
-	interpreter *maude.Client
+	// Guards the session, which is also updated by the background job
+	mutex       sync.Mutex
+	// Warm interpreters with the current source file loaded
+	pool        *maude.Pool
+	// Path and modification time of the source file loaded in the pool
+	source      string
+	sourceTime  time.Time
+	// Interpreter running the model checker (if any)
+	running     *maude.Client
 	status      sessionStatus
-	// Name of the generated model checker dump file
+	// Web URL of the model checker dump file being viewed
 	dumpfile    string
+	// Web URL of the dump produced by the last model checker run
+	resultfile  string
 	// Metadata to inform while waiting for the model checker
 	inputData   inputData
 	waitChannel chan struct{}
+	// Error of the last model checker run (if it failed)
+	failure     error
+	// Statistics of the last model checker run
+	stats       maude.Stats
 }
 
 type WebUi struct {
 	instance http.Server
+	// Path of the Maude executable
+	maudePath string
+	// Version of the Maude executable (recorded in the provenance of dumps)
+	maudeVersion string
 	assets   http.FileSystem
 	sessions mcSession
 	viewTmpl *template.Template
 	waitTmpl *template.Template
 	// Temporary directory path for auxiliary files
 	tempDir  string
+	// Private directory for the generated TLS certificate and key
+	certDir  string
+	// Access token (if token authentication is enabled)
+	token string
+	// Password hashes by user (if basic authentication is enabled)
+	passwords map[string][]byte
 	// Port is the listening port
 	Port int
 	// Address is the listening address
@@ -69,9 +97,34 @@ type WebUi struct {
 	RootDir string
 	// InitialDir is the initial directory for finding source files
 	InitialDir string
+	// DataDir is the directory where the history of runs is kept
+	// (the history is disabled if empty)
+	DataDir string
+	// MaxUploadSize is the maximum size in bytes of uploaded files
+	MaxUploadSize int64
+	// TLS enables HTTPS, with the certificate in CertFile and KeyFile or
+	// with a self-signed one generated at startup if they are empty
+	TLS bool
+	// CertFile and KeyFile are the paths of the TLS certificate and key
+	CertFile string
+	KeyFile  string
+	// PoolSize is the number of warm Maude interpreters kept for the
+	// current source file
+	PoolSize int
+	// Chain of simplifiers given in the command line
+	simplifierSpecs []util.SimplifierSpec
+	// Simplifier of the terms shown in the result view, with the operator
+	// and the file (web URL) where it is defined
+	simplifier      util.TermSimplifier
+	simplifierOp    string
+	simplifierFile  string
+	simplifierMutex sync.Mutex
+	// Cache of simplified terms for the last dump viewed
+	termCache       *util.TermCache
+	termCacheKey    string
 }
 
-func InitWebUi(maudec *maude.Client, assets http.FileSystem) *WebUi {
+func InitWebUi(maudePath string, simplifiers []util.SimplifierSpec, assets http.FileSystem) *WebUi {
 	// Loads HTML templates
 	viewTmpl, err := vfstemplate.ParseFiles(assets, nil, "result.htm")
 	if viewTmpl == nil {
@@ -91,13 +144,20 @@ func InitWebUi(maudec *maude.Client, assets http.FileSystem) *WebUi {
 
 	workingDir, _ := os.Getwd()
 
-	// Set Maude's dump path inside the temporary directory
-	maudec.SetSmcOutput(filepath.Join(tempDir, "0"))
+	// The simplifier given in the command line is the initial one
+	var simplifier = util.CreateDummySimplifier()
+
+	if len(simplifiers) > 0 {
+		if simplifier, err = util.NewSimplifierChain(simplifiers, maudePath); err != nil {
+			log.Fatal("cannot create the simplifier: ", err)
+		}
+	}
 
 	var webui = &WebUi{
+		maudePath:  maudePath,
+		maudeVersion: maude.MaudeVersion(maudePath),
 		assets:     assets,
 		sessions:   mcSession{
-			interpreter: maudec,
 			status: blank,
 		},
 		viewTmpl:   viewTmpl,
@@ -106,6 +166,11 @@ func InitWebUi(maudec *maude.Client, assets http.FileSystem) *WebUi {
 		Port:       1234,
 		RootDir:    "",
 		InitialDir: workingDir,
+		MaxUploadSize: defaultMaxUploadSize,
+		PoolSize:   2,
+		simplifierSpecs: simplifiers,
+		simplifier: simplifier,
+		simplifierOp: leadingOp(simplifiers),
 	}
 
 	webui.instance.Handler = webui
@@ -113,21 +178,42 @@ func InitWebUi(maudec *maude.Client, assets http.FileSystem) *WebUi {
 	return webui
 }
 
+func (s *WebUi) portNumber() string {
+	return strconv.FormatInt(int64(s.Port), 10)
+}
+
 func (s *WebUi) Start() {
-	var portNumber = strconv.FormatInt(int64(s.Port), 10)
-	s.instance.Addr = s.Address + ":" + portNumber
+	s.instance.Addr = s.Address + ":" + s.portNumber()
+
+	// Generates a self-signed certificate if none is given
+	if s.TLS && s.CertFile == "" {
+		certFile, keyFile, err := s.generateCertificate()
+		if err != nil {
+			log.Fatal("Cannot generate a TLS certificate: ", err)
+		}
+
+		s.CertFile, s.KeyFile = certFile, keyFile
+	}
 
 	// Opens a browser window
 	time.AfterFunc(time.Second, func() {
-		openBrowser("http://localhost:" + portNumber)
+		openBrowser(s.BaseUrl("localhost"))
 	})
 
 	// Captures ^C for to shut down the server
-	var stopChan = make(chan os.Signal)
+	var stopChan = make(chan os.Signal, 1)
 	signal.Notify(stopChan, os.Interrupt)
 
 	go func() {
-		if err := s.instance.ListenAndServe(); err != http.ErrServerClosed {
+		var err error
+
+		if s.TLS {
+			err = s.instance.ListenAndServeTLS(s.CertFile, s.KeyFile)
+		} else {
+			err = s.instance.ListenAndServe()
+		}
+
+		if err != http.ErrServerClosed {
 			log.Fatal("Cannot start server: ", err)
 		}
 	}()
@@ -137,7 +223,17 @@ func (s *WebUi) Start() {
 	signal.Reset(os.Interrupt)
 	println("\nShutting down server...")
 	s.instance.Shutdown(context.Background())
+
+	if pool := s.currentPool(); pool != nil {
+		pool.Close(context.Background())
+	}
+
+	s.currentSimplifier().Close()
 	os.RemoveAll(s.tempDir)
+
+	if s.certDir != "" {
+		os.RemoveAll(s.certDir)
+	}
 }
 
 // These structures are used to instante HTML templates
@@ -145,18 +241,39 @@ func (s *WebUi) Start() {
 type resultData struct {
 	Initial        string
 	Formula        string
+	// Whether this is the result of checking an invariant, where the path
+	// leads to the state where it fails and the cycle is that state alone
+	Invariant      bool
 	NumberOfStates int
 	Holds          bool
+	// Statistics of the model checker (if known)
+	Stats          maude.Stats
 	Path           []int32
 	Cycle          []int32
 	States         map[int32]stateData
+	// Operator and file of the simplifier applied to the terms (if any)
+	Simplifier     string
+	SimplifierFile string
+	// Truth table of the atomic propositions in the counterexample (if
+	// they can be evaluated)
+	Props          *propTable
+	// How the result was generated (if known)
+	Provenance     *provenanceData
 }
 
 type stateData struct {
 	Solution    bool
 	Term        string
+	// RawTerm is the term before simplification (if it has changed)
+	RawTerm     string
 	Strategy    string
 	Transitions []transitionData
+	// Structured views of the term, the raw term and the strategy
+	View        *termView
+	RawView     *termView
+	StratView   *termView
+	// Values of the atomic propositions of the formula (if known)
+	Props       []propData
 }
 
 type transitionData struct {
@@ -167,7 +284,10 @@ type transitionData struct {
 
 // collectStates collect all states occurring in given path in form
 // of stateData in the stateMap table.
-func collectStates(stateMap map[int32]stateData, path []int32, dump smcdump.SmcDump) {
+// Terms are simplified through the given cache.
+func collectStates(stateMap map[int32]stateData, path []int32, dump smcdump.SmcDump, terms *util.TermCache) {
+	prefetchStates(terms, path, dump)
+
 	for _, stateNr := range path {
 		if _, seen := stateMap[stateNr]; !seen {
 			var state = dump.State(stateNr)
@@ -181,28 +301,48 @@ func collectStates(stateMap map[int32]stateData, path []int32, dump smcdump.SmcD
 				}
 			}
 
+			var term, rawTerm = stateTerms(terms.Term(dump, state.Term), dump.GetString(state.Term))
+
 			stateMap[stateNr] = stateData{
 				state.Solution,
-				util.CleanString(dump.GetString(state.Term)),
+				term,
+				rawTerm,
 				util.CleanString(dump.GetString(state.Strategy)),
 				transitions,
+				nil, nil, nil, nil,
 			}
 		}
 	}
 }
 
 // translatePath translates a path from the web side to a path in the host
-// machine.
+// machine. The resulting path is confined to the root directory or, for
+// temporary files, to the temporary directory.
 func (s *WebUi) translatePath(url string) string {
 
 	if url == "" {
 		return ""
-	} else if strings.HasPrefix(url, "tmp:") && !strings.ContainsAny(url, "\\/") {
+	} else if strings.HasPrefix(url, "run:") {
+		// URL for the dumps of the runs in the history
+		return s.runDump(url[4:])
+	} else if strings.HasPrefix(url, "tmp:") {
 		// URL for temporal files generated by server operations
-		return filepath.Join(s.tempDir, url[4:])
+		// or uploaded by the user
+		var hostpath = s.tmpPath(url)
+
+		if hostpath == "" || !confine(s.tempDir, hostpath) {
+			return ""
+		}
+
+		return hostpath
 	} else {
-		nativeUrl, _ := s.web2NativeUrl(url)
-		return  nativeUrl
+		nativeUrl, isSpecial := s.confinedPath(url)
+
+		if isSpecial {
+			return ""
+		}
+
+		return nativeUrl
 	}
 }
 
@@ -215,28 +355,58 @@ func (s *WebUi) handleView(dumpfile string, writer http.ResponseWriter, request
 		return
 	}
 
-	s.sessions.dumpfile = hostpath
-
 	dump, _ := smcdump.Read(hostpath)
 	if dump == nil {
+		// Results of invariant checking are not dumps
+		if witness, err := readWitness(hostpath); err == nil {
+			s.setDumpfile(dumpfile)
+			simplifyStates(witness.States, s.currentSimplifier())
+			witness.Provenance = s.readProvenance(hostpath)
+			s.structureStates(request.Context(), dumpfile, witness)
+			s.evaluateProps(request.Context(), dumpfile, witness)
+			s.renderResult(writer, witness)
+			return
+		}
+
 		http.Error(writer, "The given file \""+dumpfile+"\" is not a valid dump.", 400)
 		return
 	}
 
+	// The dump is only made available for downloading once it has been read
+	s.setDumpfile(dumpfile)
+
+	var terms = s.termCacheFor(hostpath)
 	var stateMap = make(map[int32]stateData)
-	collectStates(stateMap, dump.Path(), dump)
-	collectStates(stateMap, dump.Cycle(), dump)
+	collectStates(stateMap, dump.Path(), dump, terms)
+	collectStates(stateMap, dump.Cycle(), dump, terms)
 
 	var resultdata = resultData{
 		util.CleanString(dump.InitialTerm()),
 		util.CleanString(dump.LtlFormula()),
+		false,
 		dump.NumberOfStates(),
 		dump.PropertyHolds(),
+		s.runStats(dumpfile),
 		dump.Path(),
 		dump.Cycle(),
 		stateMap,
+		"",
+		"",
+		nil,
+		s.readProvenance(hostpath),
 	}
 
+	s.structureStates(request.Context(), dumpfile, &resultdata)
+	s.evaluateProps(request.Context(), dumpfile, &resultdata)
+	s.renderResult(writer, &resultdata)
+}
+
+func (s *WebUi) renderResult(writer http.ResponseWriter, resultdata *resultData) {
+	s.simplifierMutex.Lock()
+	resultdata.Simplifier = s.simplifierOp
+	resultdata.SimplifierFile = s.simplifierFile
+	s.simplifierMutex.Unlock()
+
 	err := s.viewTmpl.Execute(writer, resultdata)
 
 	if err != nil {
@@ -244,17 +414,37 @@ func (s *WebUi) handleView(dumpfile string, writer http.ResponseWriter, request
 	}
 }
 
+// runStats obtains the statistics of the model checker run that produced
+// the given dump, if they are known.
+func (s *WebUi) runStats(dumpfile string) maude.Stats {
+	if strings.HasPrefix(dumpfile, "run:") {
+		if info, err := readRunInfo(s.runDir(dumpfile[4:])); err == nil {
+			return info.Stats
+		}
+	} else {
+		s.sessions.mutex.Lock()
+		defer s.sessions.mutex.Unlock()
+
+		if dumpfile == s.sessions.resultfile {
+			return s.sessions.stats
+		}
+	}
+
+	return maude.Stats{}
+}
+
 func (s *WebUi) handleLs(writer http.ResponseWriter, request *http.Request) {
 	var (
 		dir  = request.FormValue("url")
 		mode = request.FormValue("mode")
 	)
 
-	// Are we looking for dumps or for source files?
+	// Are we looking for dumps or for source files? (simplifiers
+	// are defined in source files too)
 	var dump = false
 	if mode == "dump" {
 		dump = true
-	} else if mode != "source" {
+	} else if mode != "source" && mode != "simplifier" {
 		http.Error(writer, "Bad request", 400)
 		return
 	}
@@ -276,7 +466,7 @@ func (s *WebUi) handleLs(writer http.ResponseWriter, request *http.Request) {
 		dir = s.native2WebUrl(hostdir)
 		isSpecial = false
 	} else {
-		hostdir, isSpecial = s.web2NativeUrl(dir)
+		hostdir, isSpecial = s.confinedPath(dir)
 
 		if hostdir == "" {
 			http.Error(writer, "Bad request", 400)
@@ -312,6 +502,19 @@ func (s *WebUi) handleLs(writer http.ResponseWriter, request *http.Request) {
 		for _, file := range fileList {
 			var name = file.Name()
 
+			// Symbolic links are followed only if they point inside the root
+			if file.Mode()&os.ModeSymlink != 0 {
+				var target = filepath.Join(hostdir, name)
+
+				if s.RootDir != "" && !InsideDir(s.RootDir, target) {
+					continue
+				}
+
+				if file, err = os.Stat(target); err != nil {
+					continue
+				}
+			}
+
 			if file.IsDir() && name[0] != '.' {
 				dirs = append(dirs, name)
 			} else if !dump && filepath.Ext(name) == ".maude" || dump &&
@@ -351,12 +554,14 @@ func (s *WebUi) handleSourceInfo(writer http.ResponseWriter, request *http.Reque
 		return
 	}
 
-	s.sessions.interpreter.Start()
-	s.sessions.interpreter.Load(hostpath)
-	var modules = s.sessions.interpreter.Modules()
+	modules, _, err := s.loadSource(request.Context(), hostpath, false)
+	if err != nil {
+		s.reportMaudeError(writer, err)
+		return
+	}
+
 	// Source file already loaded, but we do not know if it is valid for model checking
-	s.sessions.status = fileLoaded
-	s.sessions.inputData.File = givenfile
+	s.setFileLoaded(givenfile)
 
 	writer.Header().Set("Content-Type", "application/json")
 
@@ -376,6 +581,14 @@ type modInfo struct {
 	Type        string    `json:"type"`
 	Params      []string  `json:"params"`
 	Valid       bool      `json:"valid"`
+	// Views that can instantiate each parameter
+	Views       [][]string `json:"views"`
+	// Instance is the instantiated module expression (if any) and
+	// BadInstance tells whether it could not be built
+	Instance    string    `json:"instance"`
+	BadInstance bool      `json:"badInstance"`
+	// Messages printed by Maude when instantiating the module
+	Messages    []maude.Message `json:"messages"`
 	StateSorts  []string  `json:"stateSorts"`
 	Strategies  []maudeOp `json:"strategies"`
 	AtomicProps []maudeOp `json:"props"`
@@ -389,29 +602,96 @@ func (s *WebUi) handleModInfo(writer http.ResponseWriter, request *http.Request)
 		return
 	}
 
+	var modinfo modInfo
+	var ctx = request.Context()
+
+	err := s.withInterpreter(ctx, func(maudec *maude.Client) (err error) {
+		modinfo, err = moduleInfo(ctx, maudec, module)
+		return err
+	})
+
+	if err != nil {
+		s.reportMaudeError(writer, err)
+		return
+	}
+
+	// Updates the inner session status (parameterized modules are only
+	// valid when instantiated)
+	if modinfo.Valid && (len(modinfo.Params) == 0 || modinfo.Instance != "") {
+		s.setStatus(validModule)
+	} else {
+		s.setStatus(fileLoaded)
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(modinfo)
+}
+
+// moduleInfo collects the information about a module required to fill the
+// model checker input form. Parameterized modules are described by the
+// instance in the module expression, if any.
+func moduleInfo(ctx context.Context, maudec *maude.Client, module string) (modInfo, error) {
+	var name, views = splitInstance(module)
+
 	// Gets more information from the module signature
-	var extModInfo = s.sessions.interpreter.GetModInfo(module)
+	extModInfo, err := maudec.GetModInfo(ctx, name)
+	if err != nil {
+		return modInfo{}, err
+	}
 
 	var modinfo = modInfo{
-		Name:   module,
+		Name:   name,
 		Type:   extModInfo.Type,
 		Params: extModInfo.Params,
 		Valid:  true,
+		Views:  make([][]string, 0),
+	}
+
+	// Parameterized modules must be instantiated to be model checked
+	if len(modinfo.Params) > 0 {
+		if modinfo.Views, err = viewsFor(ctx, maudec, modinfo.Params); err != nil {
+			return modinfo, err
+		}
+
+		// Otherwise, the parameterized module itself is described
+		if len(views) == len(modinfo.Params) {
+			if name, err = resolveModule(ctx, maudec, module); isInstanceError(err) {
+				modinfo.BadInstance = true
+				modinfo.Messages = err.(*instanceError).Messages
+				name = modinfo.Name
+			} else if err != nil {
+				return modinfo, err
+			} else {
+				modinfo.Instance = modinfo.Name + "{" + strings.Join(views, ", ") + "}"
+			}
+		}
+	}
+
+	if err := maudec.Select(ctx, name); err != nil {
+		return modinfo, err
 	}
 
 	// Gets the subsorts of the model-checking State sort
-	var stateSorts, _ = s.sessions.interpreter.Subsorts("State")
+	stateSorts, _, err := maudec.Subsorts(ctx, "State")
+	if err != nil {
+		return modinfo, err
+	}
 
 	if stateSorts == nil {
 		modinfo.Valid = false
 		// If the module is not valid, the list of all sorts is returned
-		modinfo.StateSorts = s.sessions.interpreter.Sorts()
+		if modinfo.StateSorts, err = maudec.Sorts(ctx); err != nil {
+			return modinfo, err
+		}
 	} else {
 		modinfo.StateSorts = stateSorts
 	}
 
 	// Gets all the strategies in the module
-	var strats = s.sessions.interpreter.Strategies()
+	strats, err := maudec.Strategies(ctx)
+	if err != nil {
+		return modinfo, err
+	}
 
 	modinfo.Strategies = make([]maudeOp, len(strats))
 
@@ -420,7 +700,10 @@ func (s *WebUi) handleModInfo(writer http.ResponseWriter, request *http.Request)
 	}
 
 	// Gets all the atomic propositions in the module
-	var atomicProps = s.sessions.interpreter.AtomicProps()
+	atomicProps, err := maudec.AtomicProps(ctx)
+	if err != nil {
+		return modinfo, err
+	}
 
 	if atomicProps == nil {
 		modinfo.Valid = false
@@ -433,15 +716,7 @@ func (s *WebUi) handleModInfo(writer http.ResponseWriter, request *http.Request)
 		}
 	}
 
-	// Updates the inner session status
-	if modinfo.Valid {
-		s.sessions.status = validModule
-	} else {
-		s.sessions.status = fileLoaded
-	}
-
-	writer.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(writer).Encode(modinfo)
+	return modinfo, nil
 }
 
 // modelCheckResult is used to communicate whether the model checker input data
@@ -452,19 +727,27 @@ type modelCheckResult struct {
 	Status int `json:"status"`
 	// The position of the parsing error.
 	Pos    int `json:"pos"`
+	// Messages printed by Maude about the failure (if any).
+	Messages []maude.Message `json:"messages"`
 }
 
 // checkModelInput checks that the model checker input is correct. The LTL formula
 // is not checked since the LTL module may not be included when this function is called.
-func checkModelInput(maudec *maude.Client, initial, strategy string, opaques []string) (modelCheckResult, bool) {
+func checkModelInput(ctx context.Context, maudec *maude.Client, initial, strategy string, opaques []string) (modelCheckResult, bool, error) {
 	// Initial term
-	var parse = maudec.Parse(initial, "State")
-	if parse.Type != maude.Ok {
-		return modelCheckResult{1, parse.Pos}, false
+	parse, err := maudec.Parse(ctx, initial, "State")
+	if err != nil {
+		return modelCheckResult{}, false, err
+	} else if parse.Type != maude.Ok {
+		return modelCheckResult{1, parse.Pos, parse.Messages}, false, nil
 	}
 
 	// Strategy (can be a single name or an expression)
-	var strategies = maudec.Strategies()
+	strategies, err := maudec.Strategies(ctx)
+	if err != nil {
+		return modelCheckResult{}, false, err
+	}
+
 	var isName = false
 
 	if !strings.Contains(strategy, " ") {
@@ -477,9 +760,11 @@ func checkModelInput(maudec *maude.Client, initial, strategy string, opaques []s
 	}
 
 	if !isName {
-		parse = maudec.StratParse(strategy)
-		if parse.Type != maude.Ok {
-			return modelCheckResult{3, parse.Pos}, false
+		parse, err = maudec.StratParse(ctx, strategy)
+		if err != nil {
+			return modelCheckResult{}, false, err
+		} else if parse.Type != maude.Ok {
+			return modelCheckResult{3, parse.Pos, nil}, false, nil
 		}
 	}
 
@@ -494,10 +779,170 @@ func checkModelInput(maudec *maude.Client, initial, strategy string, opaques []s
 			}
 		}
 
-		return modelCheckResult{4, index}, false
+		return modelCheckResult{4, index, nil}, false, nil
+	}
+
+	return modelCheckResult{0, -1, nil}, isName, nil
+}
+
+// loadSource prepares a pool of interpreters with the given source file
+// loaded, and returns the modules it defines and the messages printed by
+// Maude while loading it. The current pool is reused if it has the same
+// source file loaded and this has not been modified since, unless reload
+// is set.
+func (s *WebUi) loadSource(ctx context.Context, hostpath string, reload bool) ([]maude.ModuleInfo, []maude.Message, error) {
+	var modTime time.Time
+
+	if stat, err := os.Stat(hostpath); err == nil {
+		modTime = stat.ModTime()
+	}
+
+	s.sessions.mutex.Lock()
+	var pool = s.sessions.pool
+	var current = pool != nil && s.sessions.source == hostpath && s.sessions.sourceTime.Equal(modTime)
+	s.sessions.mutex.Unlock()
+
+	if reload || !current {
+		// The dumps of the model checker are written in a directory of
+		// the pool with the index of the interpreter as name, so that the
+		// jobs still running on the old pool do not share them
+		poolDir, err := ioutil.TempDir(s.tempDir, "pool")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		pool, err = maude.NewPool(ctx, s.maudePath, s.PoolSize, []string{hostpath},
+			func(index int, client *maude.Client) {
+				client.SetSmcOutput(filepath.Join(poolDir, strconv.Itoa(index)))
+			})
+
+		if err != nil {
+			os.RemoveAll(poolDir)
+			return nil, nil, err
+		}
+
+		s.sessions.mutex.Lock()
+		var oldPool = s.sessions.pool
+		s.sessions.pool = pool
+		s.sessions.source = hostpath
+		s.sessions.sourceTime = modTime
+		s.sessions.mutex.Unlock()
+
+		// The old interpreters are quit in the background
+		if oldPool != nil {
+			go oldPool.Close(context.Background())
+		}
+	}
+
+	var modules []maude.ModuleInfo
+
+	err := withPool(ctx, pool, func(maudec *maude.Client) (err error) {
+		modules, err = maudec.Modules(ctx)
+		return err
+	})
+
+	return modules, pool.Messages(), err
+}
+
+// currentPool returns the pool of interpreters of the current source file.
+func (s *WebUi) currentPool() *maude.Pool {
+	s.sessions.mutex.Lock()
+	defer s.sessions.mutex.Unlock()
+
+	return s.sessions.pool
+}
+
+// setStatus changes the status of the session.
+func (s *WebUi) setStatus(status sessionStatus) {
+	s.sessions.mutex.Lock()
+	s.sessions.status = status
+	s.sessions.mutex.Unlock()
+}
+
+// setFileLoaded records that the source file with the given web URL is
+// loaded in the session.
+func (s *WebUi) setFileLoaded(file string) {
+	s.sessions.mutex.Lock()
+	s.sessions.status = fileLoaded
+	s.sessions.inputData.File = file
+	s.sessions.mutex.Unlock()
+}
+
+// loadedFile returns the web URL of the source file loaded in the session.
+func (s *WebUi) loadedFile() string {
+	s.sessions.mutex.Lock()
+	defer s.sessions.mutex.Unlock()
+
+	return s.sessions.inputData.File
+}
+
+// currentDumpfile returns the web URL of the dump being viewed.
+func (s *WebUi) currentDumpfile() string {
+	s.sessions.mutex.Lock()
+	defer s.sessions.mutex.Unlock()
+
+	return s.sessions.dumpfile
+}
+
+// setDumpfile records the web URL of the dump being viewed.
+func (s *WebUi) setDumpfile(dumpfile string) {
+	s.sessions.mutex.Lock()
+	s.sessions.dumpfile = dumpfile
+	s.sessions.mutex.Unlock()
+}
+
+// withInterpreter checks out an interpreter from the pool of the current
+// source file and calls fn with it.
+func (s *WebUi) withInterpreter(ctx context.Context, fn func(maudec *maude.Client) error) error {
+	return withPool(ctx, s.currentPool(), fn)
+}
+
+// withPool checks out an interpreter from the given pool and calls fn with
+// it. The interpreter is replaced if fn fails.
+func withPool(ctx context.Context, pool *maude.Pool, fn func(maudec *maude.Client) error) error {
+	if pool == nil {
+		return maude.ErrNotRunning
 	}
 
-	return modelCheckResult{0, -1}, isName
+	maudec, err := pool.Get(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = fn(maudec)
+	pool.Put(maudec, err)
+
+	return err
+}
+
+func (s *WebUi) handlePoolStats(writer http.ResponseWriter, request *http.Request) {
+	var stats maude.PoolStats
+
+	if pool := s.currentPool(); pool != nil {
+		stats = pool.Stats()
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(writer).Encode(stats)
+}
+
+// reportMaudeError writes an error response for a failed interaction with
+// the Maude interpreter. If no interpreter is usable, the session returns
+// to its initial state (crashed interpreters are otherwise replaced by the
+// pool).
+func (s *WebUi) reportMaudeError(writer http.ResponseWriter, err error) {
+	log.Print(err)
+
+	if instErr, ok := err.(*instanceError); ok {
+		http.Error(writer, instErr.Error(), 400)
+	} else if maude.IsCrash(err) || err == maude.ErrPoolClosed {
+		if err == maude.ErrNotRunning || err == maude.ErrPoolClosed {
+			s.setStatus(blank)
+		}
+		http.Error(writer, "The Maude interpreter has stopped unexpectedly", 503)
+	} else {
+		http.Error(writer, "Unexpected answer from the Maude interpreter", 500)
+	}
 }
 
 // removeEmptyString removes empty strings from a slice of strings.
@@ -514,34 +959,166 @@ func removeEmptyString(tokens []string) []string {
 }
 
 func (s *WebUi) handleModelcheck(writer http.ResponseWriter, request *http.Request) {
-	var (
-		module        = request.FormValue("mod")
-		initial       = request.FormValue("initial")
-		formula       = request.FormValue("formula")
-		strategy      = request.FormValue("strategy")
-		opaquesRaw    = request.FormValue("opaques")
-		namedStrategy = strategy
-	)
+	var input = inputData{
+		File:        s.loadedFile(),
+		Module:      request.FormValue("mod"),
+		InitialTerm: request.FormValue("initial"),
+		LtlFormula:  request.FormValue("formula"),
+		Strategy:    request.FormValue("strategy"),
+		Opaques:     request.FormValue("opaques"),
+	}
 
 	// Some parameters must be non-empty
-	if module == "" || initial == "" || formula == "" {
+	if input.Module == "" || input.InitialTerm == "" || input.LtlFormula == "" {
 		http.Error(writer, "Bad request", 400)
 		return
 	}
 
+	result, err := s.startModelcheck(request.Context(), input)
+	if err != nil {
+		s.reportMaudeError(writer, err)
+		return
+	}
+
 	// A JSON response will be provided
 	writer.Header().Set("Content-Type", "application/json")
-	var jsonEncoder = json.NewEncoder(writer)
+	json.NewEncoder(writer).Encode(result)
+}
+
+// startModelcheck checks the model checker input and, if correct, starts
+// the model checker in the background, putting the session in waiting state.
+// The given context only bounds the preparatory steps, not the model checker.
+func (s *WebUi) startModelcheck(ctx context.Context, input inputData) (modelCheckResult, error) {
+	var pool = s.currentPool()
+
+	if pool == nil {
+		return modelCheckResult{}, maude.ErrNotRunning
+	}
+
+	// The interpreter is kept checked out until the model checker ends
+	maudec, err := pool.Get(ctx)
+	if err != nil {
+		return modelCheckResult{}, err
+	}
+
+	result, checkModule, mcmd, err := prepareModelcheck(ctx, maudec, input)
+
+	if err != nil || result.Status != 0 {
+		pool.Put(maudec, err)
+		return result, err
+	}
+
+	// The dump is written where the interpreter has been told, inside
+	// the temporary directory
+	var dumpfile = maudec.SmcOutput()
+	var provenance = s.newProvenance(input, dumpfile)
+	relpath, _ := filepath.Rel(s.tempDir, dumpfile)
+
+	s.startJob(pool, maudec, input, "tmp:"+filepath.ToSlash(relpath),
+		func(ctx context.Context, maudec *maude.Client) (maude.Stats, string, error) {
+			result, err := maudec.ModelCheckIn(ctx, checkModule, mcmd)
+
+			if err == nil {
+				writeProvenance(dumpfile, provenance, result.Stats)
+			}
+
+			return result.Stats, dumpfile, err
+		})
+
+	return modelCheckResult{0, -1, nil}, nil
+}
+
+// backgroundJob is an operation on the interpreter that runs in the
+// background while the web interface waits for it. It returns the
+// statistics of the operation and the dump or invariant witness to be
+// saved in the history (or the empty string if there is none).
+type backgroundJob func(ctx context.Context, maudec *maude.Client) (maude.Stats, string, error)
+
+// startJob puts the session in waiting state and runs a job in the
+// background on an interpreter checked out from the pool, which is
+// checked in when the job ends. Its result will be shown from resultfile.
+func (s *WebUi) startJob(pool *maude.Pool, maudec *maude.Client, input inputData, resultfile string, job backgroundJob) {
+	// Puts the server in waiting state and stores the input data
+	input.StartTime = time.Now()
+	var waitChannel = make(chan struct{})
+
+	s.sessions.mutex.Lock()
+	s.sessions.status = waitingAnswer
+	s.sessions.inputData = input
+	s.sessions.resultfile = resultfile
+	s.sessions.running = maudec
+	s.sessions.failure = nil
+	s.sessions.stats = maude.Stats{}
+	s.sessions.waitChannel = waitChannel
+	s.sessions.mutex.Unlock()
+
+	go func() {
+		// The job runs until it finishes or it is cancelled
+		stats, dumpfile, err := job(context.Background(), maudec)
 
-	var opaques = removeEmptyString(strings.Split(opaquesRaw, " "))
+		if err != nil {
+			log.Print("the model checker has failed: ", err)
+		}
+
+		// A killed or failed interpreter is replaced by the pool
+		pool.Put(maudec, err)
+
+		// The result is stored in the history if enabled
+		if s.DataDir != "" && err == nil && dumpfile != "" {
+			if id, err := s.saveRun(input, dumpfile, stats); err == nil {
+				resultfile = "run:" + id
+			} else {
+				log.Print("cannot save the run in the history: ", err)
+			}
+		}
+
+		s.sessions.mutex.Lock()
+		s.sessions.running = nil
+		s.sessions.failure = err
+		s.sessions.resultfile = resultfile
+
+		// A failed run has no result to show
+		if err != nil {
+			s.sessions.status = blank
+		} else {
+			s.sessions.stats = stats
+			s.sessions.status = completed
+		}
+		s.sessions.mutex.Unlock()
+
+		// Closing a channel awakes all its readers
+		close(waitChannel)
+	}()
+}
+
+// prepareModelcheck checks the model checker input and prepares the module
+// where the model checker will be executed. It returns this module and the
+// term to be reduced in it.
+func prepareModelcheck(ctx context.Context, maudec *maude.Client, input inputData) (modelCheckResult, string, string, error) {
+	var (
+		initial       = input.InitialTerm
+		formula       = input.LtlFormula
+		strategy      = input.Strategy
+		namedStrategy = strategy
+	)
+
+	var opaques = removeEmptyString(strings.Split(input.Opaques, " "))
+
+	// Instances of parameterized modules are generated
+	module, err := resolveModule(ctx, maudec, input.Module)
+	if err != nil {
+		return modelCheckResult{}, "", "", err
+	}
 
 	// Checks that the model cheker input is syntactically correct
-	s.sessions.interpreter.Select(module)
-	var result, isName = checkModelInput(s.sessions.interpreter, initial, strategy, opaques)
+	if err := maudec.Select(ctx, module); err != nil {
+		return modelCheckResult{}, "", "", err
+	}
 
-	if result.Status != 0 {
-		jsonEncoder.Encode(result)
-		return
+	result, isName, err := checkModelInput(ctx, maudec, initial, strategy, opaques)
+
+	if err != nil || result.Status != 0 {
+		return result, "", "", err
 	}
 
 	// Prepare the opaques as a QidList term
@@ -554,84 +1131,109 @@ func (s *WebUi) handleModelcheck(writer http.ResponseWriter, request *http.Reque
 	// The input module need not include the strategy model checker
 	// module or the LTL module. To execute the model checker, we
 	// need to create a new module including it.
-	var hasSmc = s.sessions.interpreter.SmcAvailable()
+	hasSmc, err := maudec.SmcAvailable(ctx)
+	if err != nil {
+		return modelCheckResult{}, "", "", err
+	}
+
+	var checkModule = module
 
 	if !hasSmc || !isName {
-		var tmpModule = `smod %SMCVIEW-MODULE is
-	protecting ` + module + ` .
-	including STRATEGY-MODEL-CHECKER .
-`
+		var imports = []term.Import{
+			{Mode: "protecting", Module: module},
+			{Mode: "including", Module: "STRATEGY-MODEL-CHECKER"},
+		}
+
+		var decls = ""
+
 		if !isName {
-			tmpModule += `	strat %smcview-strat @ State .
-	sd %smcview-strat := ` + strategy + ` .
-`
+			decls = "\tstrat %smcview-strat @ State .\n" +
+				"\tsd %smcview-strat := " + strategy + " .\n"
+			namedStrategy = "%smcview-strat"
+		}
+
+		// Errors in the strategy expression (like unbound variables) are
+		// reported by the wrapper
+		messages, ok, err := maudec.DeclareWrapper(ctx, "%SMCVIEW-MODULE", imports, decls)
+
+		if err != nil {
+			return modelCheckResult{}, "", "", err
+		} else if !ok || maude.HasErrors(messages) {
+			return modelCheckResult{3, -1, messages}, "", "", nil
 		}
 
-		tmpModule += "endsm"
-		// Possible errors (unbounded variables in strategy expression,
-		// for example) are not checked here.
-		s.sessions.interpreter.RawInput(tmpModule)
-		namedStrategy = "%smcview-strat"
+		checkModule = "%SMCVIEW-MODULE"
+
+		// The formula is parsed in the wrapper, which includes the LTL module
+		if err := maudec.Select(ctx, checkModule); err != nil {
+			return modelCheckResult{}, "", "", err
+		}
 	}
 
 	// Checks the LTL formula (not done before because the input module
 	// need not include the LTL module)
-	if parse := s.sessions.interpreter.Parse(formula, "Formula"); parse.Type != maude.Ok {
-		jsonEncoder.Encode(modelCheckResult{2, parse.Pos})
-		return
-	}
-
-	// Puts the server in waiting state and stores the input data
-	s.sessions.status = waitingAnswer
-	s.sessions.inputData = inputData{
-		s.sessions.inputData.File,
-		module,
-		initial,
-		formula,
-		strategy,
-		opaquesRaw,
-		time.Now(),
+	if parse, err := maudec.Parse(ctx, formula, "Formula"); err != nil {
+		return modelCheckResult{}, "", "", err
+	} else if parse.Type != maude.Ok {
+		return modelCheckResult{2, parse.Pos, parse.Messages}, "", "", nil
 	}
 
 	var mcmd = "modelCheck(" + initial + ", " + formula + ", '" + namedStrategy + ", " + opaqueQids + ")"
 
-	s.sessions.waitChannel = make(chan struct{})
-
-	go func() {
-		s.sessions.interpreter.Reduce(mcmd)
-		s.sessions.interpreter.Select(module)
-		s.sessions.status = completed
-		// Closing a channel awakes all its readers
-		close(s.sessions.waitChannel)
-	}()
-
-	jsonEncoder.Encode(modelCheckResult{0, -1})
+	return modelCheckResult{0, -1, nil}, checkModule, mcmd, nil
 }
 
 func (s *WebUi) handleWait(writer http.ResponseWriter, request *http.Request) {
 	// If the interface is waiting for the model checker output, listen
 	// at the wait channel
-	if s.sessions.status == waitingAnswer {
-		<-s.sessions.waitChannel
+	s.sessions.mutex.Lock()
+	var status, waitChannel = s.sessions.status, s.sessions.waitChannel
+	s.sessions.mutex.Unlock()
+
+	if status == waitingAnswer {
+		<-waitChannel
 	}
 
-	// For the moment, we do not need Maude after the model checking is done
+	s.sessions.mutex.Lock()
 	s.sessions.status = blank
-	s.sessions.interpreter.QuitTimeout(250)
+	var failure, resultfile = s.sessions.failure, s.sessions.resultfile
+	s.sessions.mutex.Unlock()
+
+	if failure != nil {
+		if maude.IsCrash(failure) {
+			http.Error(writer, "The model checker has been interrupted or Maude has crashed ("+
+				failure.Error()+")", 500)
+		} else {
+			http.Error(writer, "The model checker has failed ("+failure.Error()+")", 500)
+		}
+		return
+	}
 
-	http.Error(writer, "tmp:0", 200)
+	http.Error(writer, resultfile, 200)
 }
 
 func (s *WebUi) handleAsk(writer http.ResponseWriter, request *http.Request) {
 	var question = request.FormValue("question")
 
 	switch question {
-		case "ls"         : s.handleLs(writer, request)
-		case "modinfo"    : s.handleModInfo(writer, request)
-		case "sourceinfo" : s.handleSourceInfo(writer, request)
-		case "modelcheck" : s.handleModelcheck(writer, request)
-		case "wait"       : s.handleWait(writer, request)
-		default           : http.Error(writer, "Not found", 404)
+		case "ls"            : s.handleLs(writer, request)
+		case "modinfo"       : s.handleModInfo(writer, request)
+		case "sourceinfo"    : s.handleSourceInfo(writer, request)
+		case "readsource"    : s.handleReadSource(writer, request)
+		case "savesource"    : s.handleSaveSource(writer, request)
+		case "modelcheck"    : s.handleModelcheck(writer, request)
+		case "invariant"     : s.handleInvariant(writer, request)
+		case "wait"          : s.handleWait(writer, request)
+		case "history"       : s.handleHistory(writer, request)
+		case "updaterun"     : s.handleUpdateRun(writer, request)
+		case "deleterun"     : s.handleDeleteRun(writer, request)
+		case "rerun"         : s.handleRerun(writer, request)
+		case "poolstats"     : s.handlePoolStats(writer, request)
+		case "simulate"      : s.handleSimulate(writer, request)
+		case "simplifier"    : s.handleSimplifier(writer, request)
+		case "setsimplifier" : s.handleSetSimplifier(writer, request)
+		case "transition"    : s.handleTransitionSource(writer, request)
+		default              : http.Error(writer, "Not found", 404)
 	}
 }
 
@@ -644,15 +1246,19 @@ func (s *WebUi) handleMain(writer http.ResponseWriter, request *http.Request) {
 		return
 	}
 
-	switch s.sessions.status {
+	s.sessions.mutex.Lock()
+	var status, input, resultfile = s.sessions.status, s.sessions.inputData, s.sessions.resultfile
+	s.sessions.mutex.Unlock()
+
+	switch status {
 	case waitingAnswer:
-		err := s.waitTmpl.Execute(writer, s.sessions.inputData)
+		err := s.waitTmpl.Execute(writer, input)
 
 		if err != nil {
 			log.Fatal(err)
 		}
 	case completed:
-		s.handleView("tmp:0", writer, request)
+		s.handleView(resultfile, writer, request)
 	default:
 		s.serveAsset(writer, request, "select.htm")
 	}
@@ -660,21 +1266,27 @@ func (s *WebUi) handleMain(writer http.ResponseWriter, request *http.Request) {
 
 func (s *WebUi) handleGet(writer http.ResponseWriter, request *http.Request) {
 	var which = request.FormValue("file")
+	var dumpfile = s.translatePath(s.currentDumpfile())
+
+	if dumpfile == "" {
+		http.Error(writer, "Not found", 404)
+		return
+	}
 
 	// Gets files from the temporary directory
 	switch which {
 		case "dump" :
 			writer.Header().Set("Content-Disposition", "attachment; filename=\"modelchecker.dump\"")
 
-			if file, err := os.Open(s.sessions.dumpfile); err == nil {
+			if file, err := os.Open(dumpfile); err == nil {
 				http.ServeContent(writer, request, "modelchecker.dump", time.Now(), file)
 			} else {
 				http.Error(writer, "Not found", 404)
 			}
 		case "autdot" :
 			// Generates the automaton graph (it could be cached) in DOT format
-			var grph = grapher.MakeGrapher(grapher.Legend, util.CreateDummySimplifier())
-			var dump, err = smcdump.Read(s.sessions.dumpfile)
+			var grph = grapher.MakeCachedGrapher(grapher.Legend, s.termCacheFor(dumpfile))
+			var dump, err = smcdump.Read(dumpfile)
 			if err != nil {
 				http.Error(writer, "Not found", 404) ; return
 			}
@@ -699,8 +1311,15 @@ func (s *WebUi) handleGet(writer http.ResponseWriter, request *http.Request) {
 }
 
 func (s *WebUi) handleCancel(writer http.ResponseWriter, request *http.Request) {
+	s.sessions.mutex.Lock()
 	s.sessions.status = blank
-	s.sessions.interpreter.Kill()
+	var running = s.sessions.running
+	s.sessions.mutex.Unlock()
+
+	// The pool will replace the killed interpreter
+	if running != nil {
+		running.Kill()
+	}
 
 	// Redirects to the initial screen
 	http.Redirect(writer, request, "/", 302)
@@ -716,14 +1335,27 @@ func (s *WebUi) serveAsset(writer http.ResponseWriter, request *http.Request, na
 }
 
 func (s *WebUi) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
-	switch res := request.URL.Path; res {
+	var res = request.URL.Path
+
+	// Only static assets can be accessed without authentication
+	switch res {
+		case "/smcview.css", "/smcview.js", "/smcgraph.js":
+		default:
+			if !s.authenticate(writer, request) {
+				return
+			}
+	}
+
+	switch res {
 		case "/smcview.css"	: s.serveAsset(writer, request, "smcview.css")
 		case "/smcview.js"	: s.serveAsset(writer, request, "smcview.js")
 		case "/smcgraph.js"	: s.serveAsset(writer, request, "smcgraph.js")
 		case "/"		: s.handleMain(writer, request)
+		case "/history"		: s.serveAsset(writer, request, "history.htm")
 		case "/ask"		: s.handleAsk(writer, request)
 		case "/cancel"		: s.handleCancel(writer, request)
 		case "/get"		: s.handleGet(writer, request)
+		case "/upload"		: s.handleUpload(writer, request)
 		default			: http.Error(writer, "File not found", 404)
 	}
 }
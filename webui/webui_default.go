@@ -15,22 +15,28 @@ import (
 // to a special directory (used only for accessing to the logical volumes
 // in Windows).
 func (s *WebUi) web2NativeUrl(webUrl string) (string, bool) {
-	webUrl = path.Clean(webUrl)
-
-	// Attempt to access outside the root directory
-	if strings.Contains(webUrl, "..") {
+	// Web URLs are absolute, and cleaning them as such removes any
+	// parent directory reference that would escape from the root
+	if !strings.HasPrefix(webUrl, "/") {
 		return "", false
 	}
 
-	return filepath.Join(s.RootDir, webUrl), false
+	webUrl = path.Clean(webUrl)
+
+	return filepath.Join(s.RootDir, filepath.FromSlash(webUrl)), false
 }
 
 // native2WebUrl translates a native URL to one suitable to be transmitted
 // to the browser.
 func (s *WebUi) native2WebUrl(nativeUrl string) string {
 	if s.RootDir != "" {
+		// Paths outside the root are mapped to the root itself
+		if !InsideDir(s.RootDir, nativeUrl) {
+			return "/"
+		}
+
 		nativeUrl, _ := filepath.Rel(s.RootDir, nativeUrl)
-		return path.Join("/", nativeUrl)
+		return path.Join("/", filepath.ToSlash(nativeUrl))
 	}
 
 	return nativeUrl
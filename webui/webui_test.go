@@ -0,0 +1,100 @@
+package webui
+
+import (
+	"context"
+	"github.com/ningit/smcview/maude"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakePool starts a pool with a shell script that imitates an idle
+// interpreter, and returns it with a function to close it.
+func fakePool(t *testing.T) (*maude.Pool, func()) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake interpreter is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "smcview-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var path = filepath.Join(dir, "maude")
+	var script = "#!/bin/sh\nprintf 'Maude> '\nexec cat > /dev/null\n"
+
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	pool, err := maude.NewPool(context.Background(), path, 1, nil, nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return pool, func() {
+		pool.Close(context.Background())
+		os.RemoveAll(dir)
+	}
+}
+
+// TestJobSession checks that the session can be queried while a background
+// job updates it (run with -race).
+func TestJobSession(t *testing.T) {
+	pool, cleanup := fakePool(t)
+	defer cleanup()
+
+	var s = &WebUi{sessions: mcSession{pool: pool, status: blank}}
+
+	maudec, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var release = make(chan struct{})
+
+	s.startJob(pool, maudec, inputData{File: "test.maude"}, "tmp:result",
+		func(ctx context.Context, maudec *maude.Client) (maude.Stats, string, error) {
+			<-release
+			return maude.Stats{Available: true, Rewrites: 42}, "", nil
+		})
+
+	var done = make(chan *httptest.ResponseRecorder)
+
+	go func() {
+		var recorder = httptest.NewRecorder()
+		s.handleWait(recorder, httptest.NewRequest("GET", "/ask?question=wait", nil))
+		done <- recorder
+	}()
+
+	// The session is read and written while the job is running
+	s.runStats("tmp:result")
+	s.handlePoolStats(httptest.NewRecorder(), httptest.NewRequest("GET", "/ask?question=poolstats", nil))
+	s.setDumpfile("tmp:other")
+	s.loadedFile()
+
+	close(release)
+
+	select {
+	case recorder := <-done:
+		if recorder.Code != 200 || recorder.Body.String() != "tmp:result\n" {
+			t.Errorf("the wait answer is %d %q, expected the result file", recorder.Code, recorder.Body.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the wait request has not been answered")
+	}
+
+	if stats := s.runStats("tmp:result"); stats.Rewrites != 42 {
+		t.Errorf("the stats of the job are %+v, expected those returned by the job", stats)
+	}
+
+	if stats := pool.Stats(); stats.CheckedOut != 0 {
+		t.Errorf("the interpreter has not been checked in after the job (%+v)", stats)
+	}
+}
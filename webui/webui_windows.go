@@ -9,13 +9,14 @@ import (
 )
 
 func (s *WebUi) web2NativeUrl(webUrl string) (string, bool) {
-	webUrl = path.Clean(webUrl)
-
-	// Attempt to access outside the root directory
-	if strings.Contains(webUrl, "..") {
+	// Web URLs are absolute, and cleaning them as such removes any
+	// parent directory reference that would escape from the root
+	if !strings.HasPrefix(webUrl, "/") || strings.Contains(webUrl, "\\") {
 		return "", false
 	}
 
+	webUrl = path.Clean(webUrl)
+
 	if s.RootDir == "" {
 		if webUrl == "/" {
 			// The root web url is the volume list in Windows
@@ -32,8 +33,13 @@ func (s *WebUi) web2NativeUrl(webUrl string) (string, bool) {
 
 func (s *WebUi) native2WebUrl(nativeUrl string) string {
 	if s.RootDir != "" {
+		// Paths outside the root are mapped to the root itself
+		if !InsideDir(s.RootDir, nativeUrl) {
+			return "/"
+		}
+
 		nativeUrl, _ := filepath.Rel(s.RootDir, nativeUrl)
-		return filepath.Join("/", filepath.ToSlash(nativeUrl))
+		return path.Join("/", filepath.ToSlash(nativeUrl))
 	}
 
 	if !filepath.IsAbs(nativeUrl) {